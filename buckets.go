@@ -0,0 +1,129 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+)
+
+// BucketsApi provides methods for managing buckets
+type BucketsApi interface {
+	// FindBucketByName returns the bucket with the name.
+	// Returns *Error with StatusCode http.StatusNotFound if there is no such bucket
+	FindBucketByName(ctx context.Context, name string) (*domain.Bucket, error)
+	// CreateBucket creates a new bucket and returns it as stored by the server.
+	// Bucket name and OrgID are required, RetentionRules set data retention, no rules mean data never expires
+	CreateBucket(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error)
+	// UpdateBucket updates name, description and retention rules of the bucket identified by its Id
+	UpdateBucket(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error)
+	// DeleteBucket deletes the bucket with the id
+	DeleteBucket(ctx context.Context, id string) error
+}
+
+// bucketsApiImpl implements BucketsApi interface
+type bucketsApiImpl struct {
+	client InfluxDBClient
+}
+
+func newBucketsApiImpl(client InfluxDBClient) *bucketsApiImpl {
+	return &bucketsApiImpl{client: client}
+}
+
+func (b *bucketsApiImpl) FindBucketByName(ctx context.Context, name string) (*domain.Bucket, error) {
+	bucketsUrl, err := b.bucketsUrl("")
+	if err != nil {
+		return nil, err
+	}
+	bucketsUrl.RawQuery = url.Values{"name": []string{name}}.Encode()
+	var buckets domain.Buckets
+	perror := b.client.getRequest(ctx, bucketsUrl.String(), nil, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&buckets)
+	})
+	if perror != nil {
+		return nil, perror
+	}
+	if buckets.Buckets == nil || len(*buckets.Buckets) == 0 {
+		return nil, &Error{StatusCode: http.StatusNotFound, Code: "not found", Message: fmt.Sprintf("bucket '%s' not found", name)}
+	}
+	return &(*buckets.Buckets)[0], nil
+}
+
+func (b *bucketsApiImpl) CreateBucket(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error) {
+	bucketsUrl, err := b.bucketsUrl("")
+	if err != nil {
+		return nil, err
+	}
+	return b.sendBucket(ctx, http.MethodPost, bucketsUrl.String(), bucket)
+}
+
+func (b *bucketsApiImpl) UpdateBucket(ctx context.Context, bucket *domain.Bucket) (*domain.Bucket, error) {
+	if bucket.Id == nil || *bucket.Id == "" {
+		return nil, fmt.Errorf("bucket id is required for update")
+	}
+	bucketsUrl, err := b.bucketsUrl(*bucket.Id)
+	if err != nil {
+		return nil, err
+	}
+	return b.sendBucket(ctx, http.MethodPatch, bucketsUrl.String(), bucket)
+}
+
+func (b *bucketsApiImpl) DeleteBucket(ctx context.Context, id string) error {
+	bucketsUrl, err := b.bucketsUrl(id)
+	if err != nil {
+		return err
+	}
+	perror := b.client.deleteRequest(ctx, bucketsUrl.String(), nil, func(resp *http.Response) error {
+		return resp.Body.Close()
+	})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
+// sendBucket sends the bucket as JSON using the method and decodes the bucket from the response
+func (b *bucketsApiImpl) sendBucket(ctx context.Context, method, url string, bucket *domain.Bucket) (*domain.Bucket, error) {
+	body, err := json.Marshal(bucket)
+	if err != nil {
+		return nil, err
+	}
+	setContentType := func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	var result domain.Bucket
+	decode := func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&result)
+	}
+	var perror *Error
+	if method == http.MethodPost {
+		perror = b.client.postRequest(ctx, url, bytes.NewReader(body), setContentType, decode)
+	} else {
+		perror = b.client.patchRequest(ctx, url, bytes.NewReader(body), setContentType, decode)
+	}
+	if perror != nil {
+		return nil, perror
+	}
+	return &result, nil
+}
+
+// bucketsUrl returns url of the buckets endpoint, or of the bucket with id if not empty
+func (b *bucketsApiImpl) bucketsUrl(id string) (*url.URL, error) {
+	u, err := url.Parse(b.client.ServerUrl())
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, "/api/v2/buckets", id)
+	return u, nil
+}
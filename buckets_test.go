@@ -0,0 +1,111 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketsApi(t *testing.T) {
+	buckets := make(map[string]domain.Bucket)
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		id := r.URL.Path[len("/api/v2/buckets"):]
+		if len(id) > 0 {
+			id = id[1:]
+		}
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			list := make([]domain.Bucket, 0)
+			for _, b := range buckets {
+				if b.Name == r.URL.Query().Get("name") {
+					list = append(list, b)
+				}
+			}
+			json.NewEncoder(w).Encode(domain.Buckets{Buckets: &list})
+		case (r.Method == http.MethodPost && id == "") || (r.Method == http.MethodPatch && id != ""):
+			var b domain.Bucket
+			if err := json.NewDecoder(r.Body).Decode(&b); err != nil || r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if id == "" {
+				id = "b" + string(rune('0'+len(buckets)))
+				b.Id = &id
+				w.WriteHeader(http.StatusCreated)
+			}
+			buckets[id] = b
+			json.NewEncoder(w).Encode(b)
+		case r.Method == http.MethodDelete:
+			if _, ok := buckets[id]; !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"code":"not found","message":"bucket not found"}`))
+				return
+			}
+			delete(buckets, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	bucketsApi := NewClient(server.URL, "my-token").BucketsApi()
+	ctx := context.Background()
+
+	orgId := "org1"
+	created, err := bucketsApi.CreateBucket(ctx, &domain.Bucket{
+		Name:           "my-bucket",
+		OrgID:          &orgId,
+		RetentionRules: domain.RetentionRules{{EverySeconds: 3600, Type: "expire"}},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, created.Id)
+	assert.Equal(t, "b0", *created.Id)
+	assert.Equal(t, domain.RetentionRules{{EverySeconds: 3600, Type: "expire"}}, created.RetentionRules)
+
+	found, err := bucketsApi.FindBucketByName(ctx, "my-bucket")
+	require.Nil(t, err)
+	assert.Equal(t, created, found)
+
+	desc := "updated"
+	found.Description = &desc
+	found.RetentionRules = nil
+	updated, err := bucketsApi.UpdateBucket(ctx, found)
+	require.Nil(t, err)
+	require.NotNil(t, updated.Description)
+	assert.Equal(t, "updated", *updated.Description)
+	assert.Len(t, updated.RetentionRules, 0)
+
+	_, err = bucketsApi.UpdateBucket(ctx, &domain.Bucket{Name: "no-id"})
+	assert.NotNil(t, err)
+
+	err = bucketsApi.DeleteBucket(ctx, "b0")
+	require.Nil(t, err)
+
+	_, err = bucketsApi.FindBucketByName(ctx, "my-bucket")
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, perror.StatusCode)
+
+	err = bucketsApi.DeleteBucket(ctx, "b0")
+	require.NotNil(t, err)
+	perror, ok = err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, perror.StatusCode)
+	assert.Equal(t, "not found: bucket not found", perror.Error())
+
+	assert.Equal(t, []string{"POST", "GET", "PATCH", "DELETE", "GET", "DELETE"}, methods)
+}
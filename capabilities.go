@@ -0,0 +1,62 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+)
+
+// Capabilities holds features advertised by the server
+type Capabilities struct {
+	// Routes lists API endpoints of the server
+	Routes domain.Routes
+	// Flags holds feature flags of the server by their keys. Empty if the server doesn't expose feature flags
+	Flags map[string]interface{}
+}
+
+// Flag returns value of the feature flag and true if the server advertises it
+func (c *Capabilities) Flag(key string) (interface{}, bool) {
+	v, ok := c.Flags[key]
+	return v, ok
+}
+
+// Enabled returns true if the server advertises the boolean feature flag and it is on
+func (c *Capabilities) Enabled(key string) bool {
+	v, ok := c.Flags[key].(bool)
+	return ok && v
+}
+
+func (c *client) ServerCapabilities(ctx context.Context) (*Capabilities, error) {
+	apiUrl, err := url.Parse(c.serverUrl)
+	if err != nil {
+		return nil, err
+	}
+	apiUrl.Path = path.Join(apiUrl.Path, "/api/v2")
+	flagsUrl := *apiUrl
+	flagsUrl.Path = path.Join(flagsUrl.Path, "flags")
+	capabilities := &Capabilities{Flags: make(map[string]interface{})}
+	perror := c.getRequest(ctx, apiUrl.String(), nil, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&capabilities.Routes)
+	})
+	if perror != nil {
+		return nil, perror
+	}
+	perror = c.getRequest(ctx, flagsUrl.String(), nil, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&capabilities.Flags)
+	})
+	// servers without feature flags respond with not found
+	if perror != nil && perror.StatusCode != http.StatusNotFound {
+		return nil, perror
+	}
+	return capabilities, nil
+}
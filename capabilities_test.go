@@ -0,0 +1,67 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCapabilities(t *testing.T) {
+	flags := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v2":
+			w.Write([]byte(`{"buckets":"/api/v2/buckets","query":{"self":"/api/v2/query","ast":"/api/v2/query/ast"},"write":"/api/v2/write"}`))
+		case r.URL.Path == "/api/v2/flags" && flags:
+			w.Write([]byte(`{"newLabels":true,"queryCacheForDashboards":false,"appMetrics":"on","maxLimit":10}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not found","message":"path not found"}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "my-token")
+
+	c, err := client.ServerCapabilities(context.Background())
+	require.Nil(t, err)
+	require.NotNil(t, c.Routes.Buckets)
+	assert.Equal(t, "/api/v2/buckets", *c.Routes.Buckets)
+	require.NotNil(t, c.Routes.Query)
+	require.NotNil(t, c.Routes.Query.Ast)
+	assert.Equal(t, "/api/v2/query/ast", *c.Routes.Query.Ast)
+	assert.Len(t, c.Flags, 4)
+	assert.True(t, c.Enabled("newLabels"))
+	assert.False(t, c.Enabled("queryCacheForDashboards"))
+	assert.False(t, c.Enabled("appMetrics"))
+	assert.False(t, c.Enabled("unknown"))
+	v, ok := c.Flag("appMetrics")
+	assert.True(t, ok)
+	assert.Equal(t, "on", v)
+	v, ok = c.Flag("maxLimit")
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, v)
+	_, ok = c.Flag("unknown")
+	assert.False(t, ok)
+
+	// server without feature flags
+	flags = false
+	c, err = client.ServerCapabilities(context.Background())
+	require.Nil(t, err)
+	assert.NotNil(t, c.Routes.Buckets)
+	assert.Len(t, c.Flags, 0)
+
+	_, err = NewClient(server.URL+"/invalid", "my-token").ServerCapabilities(context.Background())
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, perror.StatusCode)
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// maxTrackedSeries is maximum number of distinct series tracked for a measurement, higher cardinality is not counted
+const maxTrackedSeries = 100000
+
+// cardinalityTracker counts distinct series, i.e. tag sets, written into each measurement.
+// Series are kept as hashes and counting stops at maxTrackedSeries, to keep memory bounded
+type cardinalityTracker struct {
+	series map[string]map[uint64]bool
+	warned map[string]bool
+	lock   sync.Mutex
+}
+
+func newCardinalityTracker() *cardinalityTracker {
+	return &cardinalityTracker{series: make(map[string]map[uint64]bool), warned: make(map[string]bool)}
+}
+
+// add records series of the measurement and logs warning to logger, once for each measurement, when its cardinality exceeds threshold
+func (c *cardinalityTracker) add(measurement, series string, threshold uint, logger Logger) {
+	h := fnv.New64a()
+	h.Write([]byte(series))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	set, ok := c.series[measurement]
+	if !ok {
+		set = make(map[uint64]bool)
+		c.series[measurement] = set
+	}
+	if len(set) < maxTrackedSeries {
+		set[h.Sum64()] = true
+	}
+	if uint(len(set)) > threshold && !c.warned[measurement] {
+		c.warned[measurement] = true
+		logger.Warnf("Cardinality of measurement %s exceeded %d series\n", measurement, threshold)
+	}
+}
+
+// counts returns number of distinct series of each measurement, or nil if nothing was tracked
+func (c *cardinalityTracker) counts() map[string]uint {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.series) == 0 {
+		return nil
+	}
+	counts := make(map[string]uint, len(c.series))
+	for m, set := range c.series {
+		counts[m] = uint(len(set))
+	}
+	return counts
+}
+
+// measurementUnescaper removes line protocol escaping of measurement
+var measurementUnescaper = strings.NewReplacer(`\,`, ",", `\ `, " ")
+
+// lineSeries returns measurement and series key, i.e. measurement with tags, of line protocol record
+func lineSeries(line string) (string, string) {
+	measurementEnd := -1
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ',':
+			if measurementEnd < 0 {
+				measurementEnd = i
+			}
+		case ' ':
+			if measurementEnd < 0 {
+				measurementEnd = i
+			}
+			return line[:measurementEnd], line[:i]
+		}
+	}
+	if measurementEnd < 0 {
+		measurementEnd = len(line)
+	}
+	return line[:measurementEnd], line
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineSeries(t *testing.T) {
+	for _, test := range []struct {
+		line        string
+		measurement string
+		series      string
+	}{
+		{"test,id=1,host=a f=1i 10", "test", "test,id=1,host=a"},
+		{"test f=1i", "test", "test"},
+		{`my\ test,id=my\ id f=1i`, `my\ test`, `my\ test,id=my\ id`},
+		{`my\,test,id=1 f=1i`, `my\,test`, `my\,test,id=1`},
+		{"test", "test", "test"},
+	} {
+		measurement, series := lineSeries(test.line)
+		assert.Equal(t, test.measurement, measurement)
+		assert.Equal(t, test.series, series)
+	}
+}
+
+func TestCardinalityTracking(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetOutput(&logOutput)
+	defer log.SetOutput(os.Stderr)
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetLogLevel(1).SetCardinalityWarnThreshold(3)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	for i := 0; i < 3; i++ {
+		writeApi.WritePoint(NewPoint("test", map[string]string{"id": fmt.Sprintf("%d", i)}, map[string]interface{}{"f": i}, time.Unix(0, 10)))
+		// same series again
+		writeApi.WriteRecord(fmt.Sprintf("test,id=%d f=%di", i, i))
+	}
+	writeApi.WriteRecord("other,id=0 f=1i")
+	assert.Equal(t, map[string]uint{"test": 3, "other": 1}, writeApi.Stats().SeriesCardinality)
+	assert.Equal(t, "", logOutput.String())
+
+	writeApi.WriteRecord("test,id=3 f=3i")
+	writeApi.WriteRecord("test,id=4 f=4i")
+	assert.Equal(t, map[string]uint{"test": 5, "other": 1}, writeApi.Stats().SeriesCardinality)
+	assert.Equal(t, 1, strings.Count(logOutput.String(), "Cardinality of measurement test exceeded 3 series"))
+	writeApi.Close()
+}
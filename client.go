@@ -7,8 +7,10 @@
 package influxdb2
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -32,24 +34,59 @@ import (
 type InfluxDBClient interface {
 	// WriteApi returns the asynchronous, non-blocking, Write client.
 	WriteApi(org, bucket string) WriteApi
+	// WriteApiWithContext returns the asynchronous, non-blocking, Write client, which sends all batches with ctx.
+	// When ctx is cancelled or its deadline exceeded, pending batches are not written nor retried anymore
+	// and the error is reported via WriteApi.Errors
+	WriteApiWithContext(ctx context.Context, org, bucket string) WriteApi
 	// WriteApi returns the synchronous, blocking, Write client.
 	WriteApiBlocking(org, bucket string) WriteApiBlocking
+	// WriteApis returns asynchronous write clients created by the client and not closed yet,
+	// e.g. for inspecting their Stats
+	WriteApis() []WriteApi
 	// QueryApi returns Query client
 	QueryApi(org string) QueryApi
-	// Close ensures all ongoing asynchronous write clients finish
-	Close()
+	// DeleteApi returns Delete client for deleting data from the bucket
+	DeleteApi(org, bucket string) DeleteApi
+	// BucketsApi returns Buckets client for managing buckets
+	BucketsApi() BucketsApi
+	// TasksApi returns Tasks client for managing flux tasks
+	TasksApi() TasksApi
+	// DefaultWriteApi returns the asynchronous, non-blocking, Write client for the org and bucket set by ClientConfig
+	DefaultWriteApi() WriteApi
+	// DefaultQueryApi returns Query client for the org set by ClientConfig
+	DefaultQueryApi() QueryApi
+	// Close ensures all ongoing asynchronous write clients finish and their background goroutines exit.
+	// Returns error if write clients are not closed within Options.CloseTimeout. In that case Close returns while
+	// the write clients are still flushing their data in the background, the goroutine closing them exits once they are closed
+	Close() error
 	// Options returns the options associated with client
 	Options() *Options
 	// ServerUrl returns the url of the server url client talks to
 	ServerUrl() string
-	// Setup sends request to initialise new InfluxDB server with user, org and bucket, and data retention period
+	// Setup sends request to initialise new InfluxDB server with user, org and bucket, and data retention period.
+	// Token issued by the server replaces authentication token of the client, preceded by Options.AuthScheme
 	// Retention period of zero will result to infinite retention
 	// and returns details about newly created entities along with the authorization object
 	Setup(ctx context.Context, username, password, org, bucket string, retentionPeriodHours int) (*domain.OnboardingResponse, error)
-	// Ready checks InfluxDB server is running
+	// Ready checks InfluxDB server is running.
+	// Request is cancelled when ctx is done, e.g. to apply a shorter deadline than the client timeout for a readiness probe
 	Ready(ctx context.Context) (bool, error)
+	// Health returns status, name, version and commit of the server.
+	// Unhealthy server is reported by the "fail" status and a message, not by an error
+	Health(ctx context.Context) (*domain.HealthCheck, error)
+	// Connect establishes connection to the server ahead of the first write or query, and verifies the server is ready
+	// and the authentication token is accepted. Returns error if the server is unreachable or the token is invalid
+	Connect(ctx context.Context) error
+	// ServerCapabilities returns API routes and feature flags advertised by the server
+	ServerCapabilities(ctx context.Context) (*Capabilities, error)
 	// Internal  method for handling posts
 	postRequest(ctx context.Context, url string, body io.Reader, requestCallback RequestCallback, responseCallback ResponseCallback) *Error
+	// Internal  method for handling gets
+	getRequest(ctx context.Context, url string, requestCallback RequestCallback, responseCallback ResponseCallback) *Error
+	// Internal  method for handling patches
+	patchRequest(ctx context.Context, url string, body io.Reader, requestCallback RequestCallback, responseCallback ResponseCallback) *Error
+	// Internal  method for handling deletes
+	deleteRequest(ctx context.Context, url string, requestCallback RequestCallback, responseCallback ResponseCallback) *Error
 }
 
 // client implements InfluxDBClient interface
@@ -60,12 +97,19 @@ type client struct {
 	writeApis     []WriteApi
 	httpClient    *http.Client
 	lock          sync.Mutex
+	// default org and bucket set by ClientConfig
+	org    string
+	bucket string
 }
 
 // Http operation callbacks
 type RequestCallback func(req *http.Request)
 type ResponseCallback func(req *http.Response) error
 
+// RequestSigner is called with a fully prepared request and its complete body, as it will be sent, just before sending the request.
+// It can be used e.g. to add a signature of the body required by a gateway. Returned error cancels the request
+type RequestSigner func(req *http.Request, body []byte) error
+
 // NewClient creates InfluxDBClient for connecting to given serverUrl with provided authentication token, with default options
 // Authentication token can be empty in case of connecting to newly installed InfluxDB server, which has not been set up yet.
 // In such case Setup will set authentication token
@@ -78,24 +122,105 @@ func NewClient(serverUrl string, authToken string) InfluxDBClient {
 // Authentication token can be empty in case of connecting to newly installed InfluxDB server, which has not been set up yet.
 // In such case Setup will set authentication token
 func NewClientWithOptions(serverUrl string, authToken string, options *Options) InfluxDBClient {
-	client := &client{
-		serverUrl:     serverUrl,
-		authorization: "Token " + authToken,
-		httpClient: &http.Client{
-			Timeout: time.Second * 20,
+	httpClient := options.HttpClient()
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout:       time.Second * 20,
+			CheckRedirect: checkRedirect(options),
 			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout: 5 * time.Second,
-				}).DialContext,
+				Proxy:               proxy(options),
+				DialContext:         newDialer(options).DialContext,
 				TLSHandshakeTimeout: 5 * time.Second,
 				TLSClientConfig:     options.TlsConfig(),
 			},
-		},
-		options:   options,
-		writeApis: make([]WriteApi, 0, 5),
+		}
+	}
+	client := &client{
+		serverUrl:     serverUrl,
+		authorization: authorization(options.AuthScheme(), authToken),
+		httpClient:    httpClient,
+		options:       options,
+		writeApis:     make([]WriteApi, 0, 5),
 	}
 	return client
 }
+
+// authorization returns Authorization header value composed of the scheme and the token, or the token alone if scheme is empty.
+// Without token, e.g. before setup, requests are sent without Authorization header
+func authorization(scheme, token string) string {
+	if token == "" || scheme == "" {
+		return token
+	}
+	return scheme + " " + token
+}
+
+// ClientConfig holds configuration of a client for apps writing into and querying from a single org and bucket
+type ClientConfig struct {
+	// ServerUrl is url of the InfluxDB server
+	ServerUrl string
+	// Token is authentication token
+	Token string
+	// Org is organization used by DefaultWriteApi and DefaultQueryApi
+	Org string
+	// Bucket is bucket used by DefaultWriteApi
+	Bucket string
+	// Options configures the client. Default nil, which means DefaultOptions
+	Options *Options
+}
+
+// NewClientFromConfig creates InfluxDBClient configured by config.
+// Write and query clients for the configured org and bucket are available via DefaultWriteApi and DefaultQueryApi
+func NewClientFromConfig(config ClientConfig) InfluxDBClient {
+	options := config.Options
+	if options == nil {
+		options = DefaultOptions()
+	}
+	c := NewClientWithOptions(config.ServerUrl, config.Token, options).(*client)
+	c.org = config.Org
+	c.bucket = config.Bucket
+	return c
+}
+
+// checkRedirect creates redirect policy of the http client according to options.
+// Authorization header is never forwarded to a host other than the original one
+func checkRedirect(options *Options) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if options.DisableRedirects() {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.URL.Host != via[0].URL.Host {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	}
+}
+
+// proxy returns proxy function of the http transport for HttpProxy option, nil if proxy is not set.
+// Invalid proxy URL is reported as an error of each request
+func proxy(options *Options) func(*http.Request) (*url.URL, error) {
+	if options.HttpProxy() == "" {
+		return nil
+	}
+	proxyUrl, err := url.Parse(options.HttpProxy())
+	if err != nil {
+		err = fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	return func(*http.Request) (*url.URL, error) {
+		return proxyUrl, err
+	}
+}
+
+// newDialer creates dialer for connections to the server configured according to options
+func newDialer(options *Options) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   time.Duration(options.DialTimeout()) * time.Millisecond,
+		KeepAlive: time.Duration(options.KeepAlive()) * time.Millisecond,
+	}
+}
+
 func (c *client) Options() *Options {
 	return c.options
 }
@@ -114,7 +239,7 @@ func (c *client) Ready(ctx context.Context) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	req.Header.Set("User-Agent", userAgent())
+	c.setHeaders(req)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return false, err
@@ -123,20 +248,122 @@ func (c *client) Ready(ctx context.Context) (bool, error) {
 	return resp.StatusCode == http.StatusOK, nil
 }
 
+func (c *client) Health(ctx context.Context) (*domain.HealthCheck, error) {
+	healthUrl, err := url.Parse(c.serverUrl)
+	if err != nil {
+		return nil, err
+	}
+	healthUrl.Path = path.Join(healthUrl.Path, "health")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// unhealthy server responds with service unavailable and the health check
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, c.handleHttpError(resp)
+	}
+	health := &domain.HealthCheck{}
+	if err := json.NewDecoder(resp.Body).Decode(health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+func (c *client) Connect(ctx context.Context) error {
+	ready, err := c.Ready(ctx)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		return fmt.Errorf("server %s is not ready", c.serverUrl)
+	}
+	bucketsUrl, err := url.Parse(c.serverUrl)
+	if err != nil {
+		return err
+	}
+	// listing a bucket is the cheapest request requiring authentication
+	bucketsUrl.Path = path.Join(bucketsUrl.Path, "/api/v2/buckets")
+	bucketsUrl.RawQuery = url.Values{"limit": []string{"1"}}.Encode()
+	perror := c.getRequest(ctx, bucketsUrl.String(), nil, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		_, err := io.Copy(ioutil.Discard, resp.Body)
+		return err
+	})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
 func (c *client) WriteApi(org, bucket string) WriteApi {
 	w := newWriteApiImpl(org, bucket, c)
-	c.writeApis = append(c.writeApis, w)
+	c.addWriteApi(w)
+	return w
+}
+
+func (c *client) WriteApiWithContext(ctx context.Context, org, bucket string) WriteApi {
+	w := newWriteApiImplWithContext(ctx, org, bucket, c)
+	c.addWriteApi(w)
 	return w
 }
 
+func (c *client) addWriteApi(w WriteApi) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pruneWriteApis()
+	c.writeApis = append(c.writeApis, w)
+}
+
+func (c *client) WriteApis() []WriteApi {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pruneWriteApis()
+	writeApis := make([]WriteApi, len(c.writeApis))
+	copy(writeApis, c.writeApis)
+	return writeApis
+}
+
+// pruneWriteApis removes closed write apis, so that client doesn't keep references to them. Must be called under lock
+func (c *client) pruneWriteApis() {
+	open := c.writeApis[:0]
+	for _, w := range c.writeApis {
+		if impl, ok := w.(*writeApiImpl); ok && impl.isClosed() {
+			continue
+		}
+		open = append(open, w)
+	}
+	// clear the tail so that removed write apis can be garbage collected
+	for i := len(open); i < len(c.writeApis); i++ {
+		c.writeApis[i] = nil
+	}
+	c.writeApis = open
+}
+
 func (c *client) WriteApiBlocking(org, bucket string) WriteApiBlocking {
 	w := newWriteApiBlockingImpl(org, bucket, c)
 	return w
 }
 
-func (c *client) Close() {
-	for _, w := range c.writeApis {
-		w.Close()
+func (c *client) Close() error {
+	done := make(chan struct{})
+	go func() {
+		for _, w := range c.WriteApis() {
+			w.Close()
+		}
+		close(done)
+	}()
+	timeout := time.Duration(c.options.CloseTimeout()) * time.Millisecond
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("write clients not closed within %s", timeout)
 	}
 }
 
@@ -147,16 +374,68 @@ func (c *client) QueryApi(org string) QueryApi {
 	}
 }
 
+func (c *client) DeleteApi(org, bucket string) DeleteApi {
+	return newDeleteApiImpl(org, bucket, c)
+}
+
+func (c *client) BucketsApi() BucketsApi {
+	return newBucketsApiImpl(c)
+}
+
+func (c *client) TasksApi() TasksApi {
+	return newTasksApiImpl(c)
+}
+
+func (c *client) DefaultWriteApi() WriteApi {
+	return c.WriteApi(c.org, c.bucket)
+}
+
+func (c *client) DefaultQueryApi() QueryApi {
+	return c.QueryApi(c.org)
+}
+
 func (c *client) postRequest(ctx context.Context, url string, body io.Reader, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	return c.doRequest(ctx, http.MethodPost, url, body, requestCallback, responseCallback)
+}
+
+func (c *client) getRequest(ctx context.Context, url string, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
+	return c.doRequest(ctx, http.MethodGet, url, nil, requestCallback, responseCallback)
+}
+
+func (c *client) patchRequest(ctx context.Context, url string, body io.Reader, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
+	return c.doRequest(ctx, http.MethodPatch, url, body, requestCallback, responseCallback)
+}
+
+func (c *client) deleteRequest(ctx context.Context, url string, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
+	return c.doRequest(ctx, http.MethodDelete, url, nil, requestCallback, responseCallback)
+}
+
+// doRequest performs authorized HTTP request of the method
+func (c *client) doRequest(ctx context.Context, method, url string, body io.Reader, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
+	signer := c.options.RequestSigner()
+	var bodyBytes []byte
+	if signer != nil && body != nil {
+		// signer needs complete body
+		var err error
+		bodyBytes, err = ioutil.ReadAll(body)
+		if err != nil {
+			return NewError(err)
+		}
+		body = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return NewError(err)
 	}
-	req.Header.Set("Authorization", c.authorization)
-	req.Header.Set("User-Agent", userAgent())
+	c.setHeaders(req)
 	if requestCallback != nil {
 		requestCallback(req)
 	}
+	if signer != nil {
+		if err := signer(req, bodyBytes); err != nil {
+			return NewError(err)
+		}
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return NewError(err)
@@ -174,6 +453,17 @@ func (c *client) postRequest(ctx context.Context, url string, body io.Reader, re
 	return nil
 }
 
+// setHeaders sets default headers, Authorization header, if the client has a token, and User-Agent header of the request
+func (c *client) setHeaders(req *http.Request) {
+	for key, value := range c.options.DefaultHeaders() {
+		req.Header.Set(key, value)
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	req.Header.Set("User-Agent", userAgent())
+}
+
 func (c *client) handleHttpError(r *http.Response) *Error {
 	// successful status code range
 	if r.StatusCode >= 200 && r.StatusCode < 300 {
@@ -6,9 +6,21 @@ package influxdb2
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/bonitoo-io/influxdb-client-go/domain"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -32,3 +44,532 @@ func TestUserAgent(t *testing.T) {
 	err = c.WriteApiBlocking("o", "b").WriteRecord(context.Background(), "a,a=a a=1i")
 	assert.Nil(t, err)
 }
+
+func TestDefaultHeaders(t *testing.T) {
+	headers := make(map[string]http.Header)
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		headers[r.URL.Path] = r.Header.Clone()
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"name":"influxdb","status":"pass"}`))
+		case "/api/v2/query":
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	options := DefaultOptions().
+		AddDefaultHeader("X-Request-ID", "123").
+		AddDefaultHeader("Zone", "eu-1").
+		AddDefaultHeader("Content-Type", "text/plain").
+		AddDefaultHeader("User-Agent", "other")
+	c := NewClientWithOptions(server.URL, "my-token", options)
+	ctx := context.Background()
+
+	_, err := c.Ready(ctx)
+	require.Nil(t, err)
+	_, err = c.Health(ctx)
+	require.Nil(t, err)
+	err = c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(ctx, "test a=1i")
+	require.Nil(t, err)
+	_, err = c.QueryApi("my-org").QueryRaw(ctx, "flux", nil)
+	require.Nil(t, err)
+
+	for _, path := range []string{"/ready", "/health", "/api/v2/write", "/api/v2/query"} {
+		require.Contains(t, headers, path)
+		assert.Equal(t, "123", headers[path].Get("X-Request-ID"), path)
+		assert.Equal(t, "eu-1", headers[path].Get("Zone"), path)
+		assert.Equal(t, userAgent(), headers[path].Get("User-Agent"), path)
+		assert.Equal(t, "Token my-token", headers[path].Get("Authorization"), path)
+	}
+	// request callback overrides default header
+	assert.Equal(t, "application/json", headers["/api/v2/query"].Get("Content-Type"))
+	assert.Equal(t, "text/plain", headers["/api/v2/write"].Get("Content-Type"))
+}
+
+func TestDialer(t *testing.T) {
+	opts := DefaultOptions()
+	assert.Equal(t, uint(5000), opts.DialTimeout())
+	dialer := newDialer(opts)
+	assert.Equal(t, 5*time.Second, dialer.Timeout)
+	assert.Equal(t, time.Duration(0), dialer.KeepAlive)
+
+	opts.SetDialTimeout(2000).SetKeepAlive(60000)
+	dialer = newDialer(opts)
+	assert.Equal(t, 2*time.Second, dialer.Timeout)
+	assert.Equal(t, time.Minute, dialer.KeepAlive)
+
+	c := NewClientWithOptions("http://localhost:9999", "x", opts)
+	assert.Equal(t, uint(2000), c.Options().DialTimeout())
+	assert.Equal(t, uint(60000), c.Options().KeepAlive())
+}
+
+func TestRedirects(t *testing.T) {
+	var authorization string
+	var queried bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queried = true
+		authorization = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "my-token")
+	_, err := client.QueryApi("my-org").QueryRaw(context.Background(), "flux", nil)
+	require.Nil(t, err)
+	assert.True(t, queried)
+	assert.Equal(t, "", authorization)
+
+	queried = false
+	client = NewClientWithOptions(server.URL, "my-token", DefaultOptions().SetDisableRedirects(true))
+	_, err = client.QueryApi("my-org").QueryRaw(context.Background(), "flux", nil)
+	require.NotNil(t, err)
+	assert.False(t, queried)
+}
+
+func TestConnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v2/buckets":
+			if r.Header.Get("Authorization") != "Token my-token" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code":"unauthorized","message":"unauthorized access"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"buckets":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL, "my-token").Connect(context.Background())
+	assert.Nil(t, err)
+
+	err = NewClient(server.URL, "invalid").Connect(context.Background())
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, perror.StatusCode)
+	assert.Equal(t, "unauthorized: unauthorized access", err.Error())
+
+	server.Close()
+	err = NewClient(server.URL, "my-token").Connect(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestHealth(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"name":"influxdb","message":"ready for queries and writes","status":"pass","checks":[],"version":"2.0.0-beta.9","commit":"d1ba1e1"}`))
+		} else {
+			w.Write([]byte(`{"name":"influxdb","message":"storage unavailable","status":"fail","checks":[]}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "my-token")
+
+	health, err := client.Health(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "influxdb", health.Name)
+	assert.Equal(t, "pass", health.Status)
+	require.NotNil(t, health.Version)
+	assert.Equal(t, "2.0.0-beta.9", *health.Version)
+	require.NotNil(t, health.Commit)
+	assert.Equal(t, "d1ba1e1", *health.Commit)
+
+	status = http.StatusServiceUnavailable
+	health, err = client.Health(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "fail", health.Status)
+	require.NotNil(t, health.Message)
+	assert.Equal(t, "storage unavailable", *health.Message)
+	assert.Nil(t, health.Version)
+
+	_, err = NewClient(server.URL+"/invalid", "my-token").Health(context.Background())
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, perror.StatusCode)
+}
+
+func TestRequestContextTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	c := NewClient(server.URL, "x")
+
+	calls := map[string]func(ctx context.Context) error{
+		"Ready": func(ctx context.Context) error {
+			_, err := c.Ready(ctx)
+			return err
+		},
+		"Health": func(ctx context.Context) error {
+			_, err := c.Health(ctx)
+			return err
+		},
+		"Setup": func(ctx context.Context) error {
+			_, err := c.Setup(ctx, "user", "password", "org", "bucket", 0)
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := call(ctx)
+			require.NotNil(t, err)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded), err.Error())
+			assert.True(t, time.Since(start) < time.Second)
+		})
+	}
+}
+
+func TestRequestSigner(t *testing.T) {
+	secret := []byte("secret")
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	var signature, expectedSignature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature")
+		expectedSignature = sign(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	c := NewClientWithOptions(server.URL, "x", DefaultOptions().SetRequestSigner(func(req *http.Request, body []byte) error {
+		req.Header.Set("X-Signature", sign(body))
+		return nil
+	}))
+	err := c.WriteApiBlocking("o", "b").WriteRecord(context.Background(), "a,a=a a=1i")
+	require.Nil(t, err)
+	assert.NotEmpty(t, signature)
+	assert.Equal(t, expectedSignature, signature)
+	assert.Equal(t, sign([]byte("a,a=a a=1i\n")), signature)
+
+	c = NewClientWithOptions(server.URL, "x", DefaultOptions().SetRequestSigner(func(req *http.Request, body []byte) error {
+		return errors.New("signing failed")
+	}))
+	err = c.WriteApiBlocking("o", "b").WriteRecord(context.Background(), "a,a=a a=1i")
+	require.NotNil(t, err)
+	assert.Equal(t, "signing failed", err.Error())
+}
+
+func TestSetupWithoutToken(t *testing.T) {
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		if _, ok := r.Header["Authorization"]; ok && r.URL.Path == "/api/v2/setup" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/setup":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"auth":{"token":"my-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	c := NewClient(server.URL, "")
+	resp, err := c.Setup(context.Background(), "my-user", "my-password", "my-org", "my-bucket", 0)
+	require.Nil(t, err)
+	require.NotNil(t, resp)
+	err = c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(context.Background(), "a,a=a a=1i")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"", "Token my-token"}, authHeaders)
+}
+
+func TestAuthScheme(t *testing.T) {
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/v2/setup":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"auth":{"token":"new-token"}}`))
+		case "/health":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"name":"influxdb","status":"pass"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	requests := func(c InfluxDBClient) {
+		authHeaders = nil
+		_, err := c.Ready(ctx)
+		require.Nil(t, err)
+		_, err = c.Health(ctx)
+		require.Nil(t, err)
+		err = c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(ctx, "a,a=a a=1i")
+		require.Nil(t, err)
+	}
+
+	assert.Equal(t, "Token", DefaultOptions().AuthScheme())
+	requests(NewClient(server.URL, "my-token"))
+	assert.Equal(t, []string{"Token my-token", "Token my-token", "Token my-token"}, authHeaders)
+
+	requests(NewClientWithOptions(server.URL, "my-token", DefaultOptions().SetAuthScheme("Bearer")))
+	assert.Equal(t, []string{"Bearer my-token", "Bearer my-token", "Bearer my-token"}, authHeaders)
+
+	// empty scheme sends the token verbatim
+	c := NewClientWithOptions(server.URL, "Custom key=abc", DefaultOptions().SetAuthScheme(""))
+	requests(c)
+	assert.Equal(t, []string{"Custom key=abc", "Custom key=abc", "Custom key=abc"}, authHeaders)
+
+	// setup replaces the token
+	_, err := c.Setup(ctx, "my-user", "my-password", "my-org", "my-bucket", 0)
+	require.Nil(t, err)
+	requests(c)
+	assert.Equal(t, []string{"new-token", "new-token", "new-token"}, authHeaders)
+}
+
+func TestSetupRetention(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"auth":{"token":"my-token"},"bucket":{"name":"my-bucket","retentionRules":[{"type":"expire","everySeconds":172800}]}}`))
+	}))
+	defer server.Close()
+	c := NewClient(server.URL, "")
+	resp, err := c.Setup(context.Background(), "my-user", "my-password", "my-org", "my-bucket", 48)
+	require.Nil(t, err)
+	retention, err := SetupRetention(resp)
+	require.Nil(t, err)
+	assert.Equal(t, 48*time.Hour, retention)
+
+	retention, err = SetupRetention(&domain.OnboardingResponse{Bucket: &domain.Bucket{Name: "my-bucket"}})
+	require.Nil(t, err)
+	assert.Equal(t, time.Duration(0), retention)
+
+	_, err = SetupRetention(&domain.OnboardingResponse{})
+	require.NotNil(t, err)
+	assert.Equal(t, "setup response has no bucket", err.Error())
+}
+
+// blockingWriteApi is WriteApi, which Close blocks until released
+type blockingWriteApi struct {
+	WriteApi
+	release chan struct{}
+}
+
+func (b *blockingWriteApi) Close() {
+	<-b.release
+}
+
+// waitForWriteProcs waits until there is expected count of running background goroutines of write clients and returns actual count
+func waitForWriteProcs(expected int) int {
+	buf := make([]byte, 1<<20)
+	count := 0
+	for i := 0; i < 100; i++ {
+		stack := string(buf[:runtime.Stack(buf, true)])
+		count = strings.Count(stack, "(*writeApiImpl).bufferProc") + strings.Count(stack, "(*writeApiImpl).writeProc")
+		if count == expected {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return count
+}
+
+// waitForGoroutines waits shortly until count of goroutines drops to max and returns the actual count
+func waitForGoroutines(max int) int {
+	count := 0
+	for i := 0; i < 100; i++ {
+		count = runtime.NumGoroutine()
+		if count <= max {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return count
+}
+
+func TestClose(t *testing.T) {
+	procs := waitForWriteProcs(0)
+	goroutines := runtime.NumGoroutine()
+	c := NewClient("http://localhost:9999", "x")
+	c.WriteApi("my-org", "my-bucket")
+	c.WriteApi("my-org", "my-bucket")
+	assert.Equal(t, procs+4, waitForWriteProcs(procs+4))
+	err := c.Close()
+	require.Nil(t, err)
+	// goroutines exit shortly after signalling they're done
+	assert.Equal(t, procs, waitForWriteProcs(procs))
+	// nothing started by the client, including the closing goroutine, is left running
+	assert.LessOrEqual(t, waitForGoroutines(goroutines), goroutines)
+
+	c = NewClientWithOptions("http://localhost:9999", "x", DefaultOptions().SetCloseTimeout(50))
+	stuck := &blockingWriteApi{release: make(chan struct{})}
+	c.(*client).writeApis = append(c.(*client).writeApis, stuck)
+	err = c.Close()
+	require.NotNil(t, err)
+	assert.Equal(t, "write clients not closed within 50ms", err.Error())
+	close(stuck.release)
+}
+
+func TestWriteApis(t *testing.T) {
+	c := NewClient("http://localhost:9999", "x")
+	assert.Len(t, c.WriteApis(), 0)
+	w1 := c.WriteApi("my-org", "my-bucket")
+	w2 := c.WriteApiWithContext(context.Background(), "my-org", "other-bucket")
+	c.WriteApiBlocking("my-org", "my-bucket")
+	writeApis := c.WriteApis()
+	require.Len(t, writeApis, 2)
+	assert.Equal(t, w1, writeApis[0])
+	assert.Equal(t, w2, writeApis[1])
+	assert.Equal(t, uint(0), writeApis[1].Stats().BufferedBytes)
+	assert.Equal(t, uint(0), writeApis[0].Stats().DroppedRecords)
+	assert.Equal(t, 0, writeApis[0].BufferedCount())
+
+	w2.Close()
+	require.Len(t, c.WriteApis(), 1)
+	assert.Equal(t, w1, c.WriteApis()[0])
+	// closed write api is not referenced by the client anymore
+	assert.Len(t, c.(*client).writeApis, 1)
+	w3 := c.WriteApi("my-org", "my-bucket")
+	w3.Close()
+	c.WriteApi("my-org", "my-bucket")
+	assert.Len(t, c.(*client).writeApis, 2)
+	require.Nil(t, c.Close())
+	assert.Len(t, c.WriteApis(), 0)
+	assert.Len(t, c.(*client).writeApis, 0)
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	var params []string
+	var lines []string
+	var lock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		params = append(params, r.URL.Path+"?"+r.URL.RawQuery)
+		lines = append(lines, string(body))
+		lock.Unlock()
+		assert.Equal(t, "Token my-token", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := NewClientFromConfig(ClientConfig{
+		ServerUrl: server.URL,
+		Token:     "my-token",
+		Org:       "my-org",
+		Bucket:    "my-bucket",
+		Options:   DefaultOptions().SetBatchSize(2),
+	})
+	assert.Equal(t, server.URL, c.ServerUrl())
+	assert.Equal(t, uint(2), c.Options().BatchSize())
+	writeApi := c.DefaultWriteApi()
+	require.Nil(t, writeApi.WriteRecord("test,a=1 f=1i 1"))
+	require.Nil(t, writeApi.WriteRecord("test,a=2 f=2i 2"))
+	require.Nil(t, c.Close())
+	lock.Lock()
+	assert.Equal(t, []string{"/api/v2/write?bucket=my-bucket&org=my-org&precision=ns"}, params)
+	assert.Equal(t, []string{"test,a=1 f=1i 1\ntest,a=2 f=2i 2\n"}, lines)
+	lock.Unlock()
+	assert.Equal(t, "my-org", c.DefaultQueryApi().(*queryApiImpl).org)
+
+	// default options are used when not set
+	c = NewClientFromConfig(ClientConfig{ServerUrl: server.URL, Token: "my-token"})
+	assert.Equal(t, DefaultOptions().BatchSize(), c.Options().BatchSize())
+}
+
+// roundTripFunc is http.RoundTripper implemented by a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCustomHttpClient(t *testing.T) {
+	var urls []string
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		urls = append(urls, req.URL.String())
+		assert.Equal(t, "Token x", req.Header.Get("Authorization"))
+		return &http.Response{StatusCode: http.StatusNoContent, Body: ioutil.NopCloser(strings.NewReader("")), Header: http.Header{}, Request: req}, nil
+	})}
+	c := NewClientWithOptions("http://localhost:9999", "x", DefaultOptions().SetHttpClient(httpClient))
+	assert.Equal(t, httpClient, c.Options().HttpClient())
+	assert.Equal(t, httpClient, c.(*client).httpClient)
+
+	err := c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(context.Background(), "test f=1i 1")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://localhost:9999/api/v2/write?bucket=my-bucket&org=my-org&precision=ns"}, urls)
+
+	// default client is created when not set
+	c = NewClient("http://localhost:9999", "x")
+	assert.Nil(t, c.Options().HttpClient())
+	assert.NotNil(t, c.(*client).httpClient)
+}
+
+func TestHttpProxy(t *testing.T) {
+	var requests []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxy receives absolute URL of the target
+		requests = append(requests, r.URL.String())
+		assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), r.Header.Get("Proxy-Authorization"))
+		assert.Equal(t, "Token x", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxyServer.Close()
+	proxyUrl := strings.Replace(proxyServer.URL, "http://", "http://user:pass@", 1)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	c := NewClientWithOptions("http://influxdb.test:9999", "x", DefaultOptions().SetHttpProxy(proxyUrl).SetTlsConfig(tlsConfig))
+	assert.Equal(t, proxyUrl, c.Options().HttpProxy())
+	err := c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(context.Background(), "test f=1i 1")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://influxdb.test:9999/api/v2/write?bucket=my-bucket&org=my-org&precision=ns"}, requests)
+	// proxy is set together with TLS configuration
+	transport := c.(*client).httpClient.Transport.(*http.Transport)
+	assert.Equal(t, tlsConfig, transport.TLSClientConfig)
+	require.NotNil(t, transport.Proxy)
+
+	c = NewClientWithOptions("http://influxdb.test:9999", "x", DefaultOptions().SetHttpProxy("http://proxy:%zz"))
+	err = c.WriteApiBlocking("my-org", "my-bucket").WriteRecord(context.Background(), "test f=1i 1")
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid proxy URL")
+
+	assert.Nil(t, NewClient("http://localhost:9999", "x").(*client).httpClient.Transport.(*http.Transport).Proxy)
+}
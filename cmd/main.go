@@ -153,16 +153,20 @@ func (w *WriterV2R) Write(id int, measurementName string, iteration int) {
 	//fmt.Println(record)
 }
 
-func (w *WriterV2R) Count(measurementName string) (int, error) {
-	query := `from(bucket:"` + InfluxDB2Bucket + `") 
+// countQuery is template of query counting points of a measurement
+const countQuery = `from(bucket: {{bucket}}) 
 		|> range(start: 0, stop: now()) 
-		|> filter(fn: (r) => r._measurement == "` + measurementName + `") 
+		|> filter(fn: (r) => r._measurement == {{measurement}}) 
 		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
 		|> drop(columns: ["id", "host"])
 		|> count(column: "temperature")`
 
+func (w *WriterV2R) Count(measurementName string) (int, error) {
 	queryApi := w.influx.QueryApi(InfluxDB2Org)
-	queryResult, err := queryApi.Query(context.Background(), query)
+	queryResult, err := queryApi.QueryTemplate(context.Background(), countQuery, map[string]string{
+		"bucket":      InfluxDB2Bucket,
+		"measurement": measurementName,
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -180,8 +184,7 @@ func (w *WriterV2R) Count(measurementName string) (int, error) {
 }
 
 func (w *WriterV2R) Close() error {
-	w.influx.Close()
-	return nil
+	return w.influx.Close()
 }
 
 func (w *WriterV2P) Write(id int, measurementName string, iteration int) {
@@ -194,15 +197,11 @@ func (w *WriterV2P) Write(id int, measurementName string, iteration int) {
 }
 
 func (w *WriterV2P) Count(measurementName string) (int, error) {
-	query := `from(bucket:"` + InfluxDB2Bucket + `") 
-		|> range(start: 0, stop: now()) 
-		|> filter(fn: (r) => r._measurement == "` + measurementName + `") 
-		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
-		|> drop(columns: ["id", "host"])
-		|> count(column: "temperature")`
-
 	queryApi := w.influx.QueryApi(InfluxDB2Org)
-	queryResult, err := queryApi.Query(context.Background(), query)
+	queryResult, err := queryApi.QueryTemplate(context.Background(), countQuery, map[string]string{
+		"bucket":      InfluxDB2Bucket,
+		"measurement": measurementName,
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -220,6 +219,5 @@ func (w *WriterV2P) Count(measurementName string) (int, error) {
 }
 
 func (w *WriterV2P) Close() error {
-	w.influx.Close()
-	return nil
+	return w.influx.Close()
 }
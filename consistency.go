@@ -0,0 +1,59 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WaitForCount repeatedly runs flux query, which returns count in the _value column of the first record,
+// until the count reaches at least expected value. Queries are run with pollInterval pause between them.
+// Returns error if ctx is done before the count is reached, or if query fails
+func WaitForCount(ctx context.Context, queryApi QueryApi, query string, expected int64, pollInterval time.Duration) error {
+	for {
+		count, err := queryCount(ctx, queryApi, query)
+		if err != nil {
+			return err
+		}
+		if count >= expected {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("count %d has not reached %d: %s", count, expected, ctx.Err().Error())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// FlushAndWaitForCount flushes all buffered data of writeApi and then waits using WaitForCount until written data are queryable.
+// It is intended for tests and demos where data written asynchronously must be read immediately
+func FlushAndWaitForCount(ctx context.Context, writeApi WriteApi, queryApi QueryApi, query string, expected int64, pollInterval time.Duration) error {
+	writeApi.Flush()
+	return WaitForCount(ctx, queryApi, query, expected, pollInterval)
+}
+
+// queryCount returns value of the first record of the query result, which must be a count, or zero if result is empty
+func queryCount(ctx context.Context, queryApi QueryApi, query string) (int64, error) {
+	result, err := queryApi.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	if !result.Next() {
+		return 0, result.Err()
+	}
+	defer result.Close()
+	switch v := result.Record().Value().(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	default:
+		return 0, errors.New("query result value is not a count")
+	}
+}
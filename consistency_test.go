@@ -0,0 +1,73 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushAndWaitForCount(t *testing.T) {
+	var lock sync.Mutex
+	written := 0
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/api/v2/write":
+			body, _ := ioutil.ReadAll(r.Body)
+			written += strings.Count(string(body), "\n")
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/query":
+			queries++
+			// simulate data being indexed with delay
+			count := 0
+			if queries > 2 {
+				count = written
+			}
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(makeCSVstring([]string{
+				`#datatype,string,long,long`,
+				`#group,false,false,false`,
+				`#default,_result,,`,
+				`,result,table,_value`,
+				fmt.Sprintf(`,,0,%d`, count),
+			})))
+		}
+	}))
+	defer server.Close()
+	client := NewClientWithOptions(server.URL, "a", DefaultOptions().SetBatchSize(100).SetFlushInterval(10000))
+	writeApi := client.WriteApi("my-org", "my-bucket")
+	queryApi := client.QueryApi("my-org")
+	for _, p := range genPoints(10) {
+		writeApi.WritePoint(p)
+	}
+	query := `from(bucket:"my-bucket") |> range(start: -1h) |> count()`
+	err := FlushAndWaitForCount(context.Background(), writeApi, queryApi, query, 10, 10*time.Millisecond)
+	require.Nil(t, err)
+	assert.Equal(t, 3, queries)
+	count, err := queryCount(context.Background(), queryApi, query)
+	require.Nil(t, err)
+	assert.Equal(t, int64(10), count)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = WaitForCount(ctx, queryApi, query, 20, 10*time.Millisecond)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+	client.Close()
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+)
+
+// DeleteApi provides methods for deleting time series data from a bucket
+type DeleteApi interface {
+	// Delete deletes points of the bucket with time in the range from start to stop, which match the predicate,
+	// e.g. _measurement="cpu" AND host="server01". Empty predicate deletes all points in the time range
+	Delete(ctx context.Context, start, stop time.Time, predicate string) error
+}
+
+// deleteApiImpl implements DeleteApi interface
+type deleteApiImpl struct {
+	org    string
+	bucket string
+	client InfluxDBClient
+}
+
+func newDeleteApiImpl(org string, bucket string, client InfluxDBClient) *deleteApiImpl {
+	return &deleteApiImpl{org: org, bucket: bucket, client: client}
+}
+
+func (d *deleteApiImpl) Delete(ctx context.Context, start, stop time.Time, predicate string) error {
+	deleteUrl, err := d.deleteUrl()
+	if err != nil {
+		return err
+	}
+	request := domain.DeletePredicateRequest{Start: start, Stop: stop}
+	if predicate != "" {
+		request.Predicate = &predicate
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	perror := d.client.postRequest(ctx, deleteUrl, bytes.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	}, func(resp *http.Response) error {
+		return resp.Body.Close()
+	})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
+func (d *deleteApiImpl) deleteUrl() (string, error) {
+	u, err := url.Parse(d.client.ServerUrl())
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, "/api/v2/delete")
+	params := u.Query()
+	params.Set("org", d.org)
+	params.Set("bucket", d.bucket)
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	var request domain.DeletePredicateRequest
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = domain.DeletePredicateRequest{}
+		query = r.URL.RawQuery
+		if r.URL.Path != "/api/v2/delete" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if request.Predicate != nil && *request.Predicate == "invalid" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"invalid","message":"invalid predicate"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "my-token")
+	deleteApi := client.DeleteApi("my-org", "my-bucket")
+
+	stop := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	start := stop.Add(-time.Hour)
+	err := deleteApi.Delete(context.Background(), start, stop, `_measurement="cpu" AND host="server01"`)
+	require.Nil(t, err)
+	assert.Equal(t, "bucket=my-bucket&org=my-org", query)
+	assert.Equal(t, start, request.Start)
+	assert.Equal(t, stop, request.Stop)
+	require.NotNil(t, request.Predicate)
+	assert.Equal(t, `_measurement="cpu" AND host="server01"`, *request.Predicate)
+
+	err = deleteApi.Delete(context.Background(), start, stop, "")
+	require.Nil(t, err)
+	assert.Nil(t, request.Predicate)
+
+	err = deleteApi.Delete(context.Background(), start, stop, "invalid")
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusBadRequest, perror.StatusCode)
+	assert.Equal(t, "invalid: invalid predicate", perror.Error())
+}
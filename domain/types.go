@@ -869,9 +869,11 @@ type HTTPNotificationRuleBase struct {
 // HealthCheck defines model for HealthCheck.
 type HealthCheck struct {
 	Checks  *[]HealthCheck `json:"checks,omitempty"`
+	Commit  *string        `json:"commit,omitempty"`
 	Message *string        `json:"message,omitempty"`
 	Name    string         `json:"name"`
 	Status  string         `json:"status"`
+	Version *string        `json:"version,omitempty"`
 }
 
 // HeatmapViewProperties defines model for HeatmapViewProperties.
@@ -33,3 +33,8 @@ func NewError(err error) *Error {
 		RetryAfter: 0,
 	}
 }
+
+// Unwrap returns the nested error, so errors.Is and errors.As can match it
+func (e *Error) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,32 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorUnwrap(t *testing.T) {
+	var err error = NewError(context.DeadlineExceeded)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, context.Canceled))
+
+	err = fmt.Errorf("write failed: %w", NewError(&url.Error{Op: "Post", URL: "http://localhost:9999", Err: context.DeadlineExceeded}))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+	var perror *Error
+	assert.True(t, errors.As(err, &perror))
+
+	err = &Error{Code: "invalid", Message: "bad request"}
+	assert.Nil(t, errors.Unwrap(err))
+	assert.False(t, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+)
+
+// paramsVariable is the name of the flux record holding query parameters, accessible in a query as v.<param>
+const paramsVariable = "v"
+
+// floatLiteral is flux AST float literal. domain.FloatLiteral holds float32 value, which would lose precision
+type floatLiteral struct {
+	Type  domain.NodeType `json:"type"`
+	Value float64         `json:"value"`
+}
+
+// paramsExtern creates extern block of a flux query defining option v = {params}
+func paramsExtern(params map[string]interface{}) (*domain.File, error) {
+	init, err := paramExpression(reflect.ValueOf(params))
+	if err != nil {
+		return nil, err
+	}
+	var assignment interface{} = domain.VariableAssignment{
+		Type: nodeType("VariableAssignment"),
+		Id:   &domain.Identifier{Type: nodeType("Identifier"), Name: strPtr(paramsVariable)},
+		Init: &init,
+	}
+	body := []domain.Statement{domain.OptionStatement{Type: nodeType("OptionStatement"), Assignment: &assignment}}
+	return &domain.File{Type: nodeType("File"), Body: &body}, nil
+}
+
+// paramExpression converts a parameter value into flux AST expression.
+// Slices and arrays are encoded as flux arrays, maps with string keys as flux records
+func paramExpression(value reflect.Value) (domain.Expression, error) {
+	if !value.IsValid() {
+		return nil, fmt.Errorf("unsupported nil parameter value")
+	}
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return domain.DateTimeLiteral{Type: nodeType("DateTimeLiteral"), Value: strPtr(v.Format(time.RFC3339Nano))}, nil
+	case time.Duration:
+		magnitude, unit := int(v.Nanoseconds()), "ns"
+		return domain.DurationLiteral{Type: nodeType("DurationLiteral"), Values: &[]domain.Duration{{Type: nodeType("Duration"), Magnitude: &magnitude, Unit: &unit}}}, nil
+	}
+	switch value.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return paramExpression(value.Elem())
+	case reflect.String:
+		return domain.StringLiteral{Type: nodeType("StringLiteral"), Value: strPtr(value.String())}, nil
+	case reflect.Bool:
+		b := value.Bool()
+		return domain.BooleanLiteral{Type: nodeType("BooleanLiteral"), Value: &b}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return domain.IntegerLiteral{Type: nodeType("IntegerLiteral"), Value: strPtr(strconv.FormatInt(value.Int(), 10))}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return domain.UnsignedIntegerLiteral{Type: nodeType("UnsignedIntegerLiteral"), Value: strPtr(strconv.FormatUint(value.Uint(), 10))}, nil
+	case reflect.Float32, reflect.Float64:
+		return floatLiteral{Type: "FloatLiteral", Value: value.Float()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]domain.Expression, value.Len())
+		for i := range elements {
+			e, err := paramExpression(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = e
+		}
+		return domain.ArrayExpression{Type: nodeType("ArrayExpression"), Elements: &elements}, nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported parameter map key type %s, expected string", value.Type().Key())
+		}
+		keys := make([]string, 0, value.Len())
+		for _, k := range value.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		properties := make([]domain.Property, len(keys))
+		for i, k := range keys {
+			e, err := paramExpression(value.MapIndex(reflect.ValueOf(k).Convert(value.Type().Key())))
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %v", k, err)
+			}
+			var key domain.PropertyKey = domain.Identifier{Type: nodeType("Identifier"), Name: strPtr(k)}
+			properties[i] = domain.Property{Type: nodeType("Property"), Key: &key, Value: &e}
+		}
+		return domain.ObjectExpression{Type: nodeType("ObjectExpression"), Properties: &properties}, nil
+	}
+	return nil, fmt.Errorf("unsupported parameter type %s", value.Type())
+}
+
+func nodeType(t string) *domain.NodeType {
+	nt := domain.NodeType(t)
+	return &nt
+}
+
+func strPtr(s string) *string {
+	return &s
+}
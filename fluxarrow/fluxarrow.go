@@ -0,0 +1,170 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+// Package fluxarrow converts flux query results into Apache Arrow records.
+// It is a separate module, so the Arrow dependency is required only by its users
+package fluxarrow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	influxdb2 "github.com/bonitoo-io/influxdb-client-go"
+)
+
+// QueryArrow executes flux query and returns its result as Arrow records, one record per flux table.
+// Records must be released by the caller
+func QueryArrow(ctx context.Context, queryApi influxdb2.QueryApi, query string, mem memory.Allocator) ([]array.Record, error) {
+	result, err := queryApi.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	return Records(result, mem)
+}
+
+// Records reads the whole query result and converts each flux table into Arrow record.
+// Arrow schema of a record is derived from data types of the table columns.
+// Records must be released by the caller
+func Records(result *influxdb2.QueryTableResult, mem memory.Allocator) ([]array.Record, error) {
+	var records []array.Record
+	var builder *array.RecordBuilder
+	var columns []*influxdb2.FluxColumn
+	release := func() {
+		if builder != nil {
+			builder.Release()
+		}
+		for _, r := range records {
+			r.Release()
+		}
+	}
+	for result.Next() {
+		if result.TableChanged() {
+			if builder != nil {
+				records = append(records, builder.NewRecord())
+				builder.Release()
+				builder = nil
+			}
+			columns = result.TableMetadata().Columns()
+			schema, err := tableSchema(columns)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			builder = array.NewRecordBuilder(mem, schema)
+		}
+		for i, c := range columns {
+			if err := appendValue(builder.Field(i), result.Record().ValueByKey(c.Name())); err != nil {
+				release()
+				return nil, fmt.Errorf("column %s: %v", c.Name(), err)
+			}
+		}
+	}
+	if result.Err() != nil {
+		release()
+		return nil, result.Err()
+	}
+	if builder != nil {
+		records = append(records, builder.NewRecord())
+		builder.Release()
+	}
+	return records, nil
+}
+
+// tableSchema creates Arrow schema of flux table columns
+func tableSchema(columns []*influxdb2.FluxColumn) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		t, err := arrowType(c.DataType())
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", c.Name(), err)
+		}
+		fields[i] = arrow.Field{Name: c.Name(), Type: t, Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// arrowType returns Arrow type of flux data type
+func arrowType(dataType string) (arrow.DataType, error) {
+	switch dataType {
+	case "string":
+		return arrow.BinaryTypes.String, nil
+	case "double":
+		return arrow.PrimitiveTypes.Float64, nil
+	case "bool":
+		return arrow.FixedWidthTypes.Boolean, nil
+	case "long":
+		return arrow.PrimitiveTypes.Int64, nil
+	case "unsignedLong":
+		return arrow.PrimitiveTypes.Uint64, nil
+	case "duration":
+		return arrow.FixedWidthTypes.Duration_ns, nil
+	case "base64Binary":
+		return arrow.BinaryTypes.Binary, nil
+	case "dateTime:RFC3339", "dateTime:RFC3339Nano":
+		return arrow.FixedWidthTypes.Timestamp_ns, nil
+	}
+	return nil, fmt.Errorf("unsupported data type %s", dataType)
+}
+
+// appendValue appends value of a flux record to the column builder, nil value is appended as null
+func appendValue(b array.Builder, value interface{}) error {
+	if value == nil {
+		b.AppendNull()
+		return nil
+	}
+	ok := true
+	switch b := b.(type) {
+	case *array.StringBuilder:
+		var v string
+		if v, ok = value.(string); ok {
+			b.Append(v)
+		}
+	case *array.Float64Builder:
+		var v float64
+		if v, ok = value.(float64); ok {
+			b.Append(v)
+		}
+	case *array.BooleanBuilder:
+		var v bool
+		if v, ok = value.(bool); ok {
+			b.Append(v)
+		}
+	case *array.Int64Builder:
+		var v int64
+		if v, ok = value.(int64); ok {
+			b.Append(v)
+		}
+	case *array.Uint64Builder:
+		var v uint64
+		if v, ok = value.(uint64); ok {
+			b.Append(v)
+		}
+	case *array.DurationBuilder:
+		var v time.Duration
+		if v, ok = value.(time.Duration); ok {
+			b.Append(arrow.Duration(v))
+		}
+	case *array.BinaryBuilder:
+		var v []byte
+		if v, ok = value.([]byte); ok {
+			b.Append(v)
+		}
+	case *array.TimestampBuilder:
+		var v time.Time
+		if v, ok = value.(time.Time); ok {
+			b.Append(arrow.Timestamp(v.UnixNano()))
+		}
+	default:
+		ok = false
+	}
+	if !ok {
+		return fmt.Errorf("unexpected value %v of type %T", value, value)
+	}
+	return nil
+}
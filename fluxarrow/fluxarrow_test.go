@@ -0,0 +1,94 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package fluxarrow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	influxdb2 "github.com/bonitoo-io/influxdb-client-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const multiTablesCSV = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string,string
+#group,false,false,true,true,false,false,true,true,true,true
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
+,,0,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T10:34:08.135814545Z,1.4,f,test,1,adsfasdf
+,,0,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.850214724Z,6.6,f,test,1,adsfasdf
+
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string,string,string
+#group,false,false,true,true,false,false,true,true,true,true
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
+,,1,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T10:34:08.135814545Z,4,i,test,1,adsfasdf
+,,1,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.850214724Z,-1,i,test,1,adsfasdf
+
+#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,bool,string,string,string,string
+#group,false,false,true,true,false,false,true,true,true,true
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
+,,2,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.62797864Z,false,f,test,0,adsfasdf
+,,2,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.969100374Z,true,f,test,0,adsfasdf
+
+#datatype,string,long,dateTime:RFC3339Nano,dateTime:RFC3339Nano,dateTime:RFC3339Nano,unsignedLong,string,string,string,string
+#group,false,false,true,true,false,false,true,true,true,true
+#default,_result,,,,,,,,,
+,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
+,,3,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.62797864Z,0,i,test,0,adsfasdf
+,,3,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.969100374Z,2,i,test,0,adsfasdf
+
+`
+
+func TestQueryArrow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(multiTablesCSV))
+	}))
+	defer server.Close()
+	client := influxdb2.NewClient(server.URL, "a")
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+
+	records, err := QueryArrow(context.Background(), client.QueryApi("org"), "flux", mem)
+	require.Nil(t, err)
+	require.Len(t, records, 4)
+	defer func() {
+		for _, r := range records {
+			r.Release()
+		}
+	}()
+	for i, valueType := range []arrow.DataType{
+		arrow.PrimitiveTypes.Float64,
+		arrow.PrimitiveTypes.Int64,
+		arrow.FixedWidthTypes.Boolean,
+		arrow.PrimitiveTypes.Uint64,
+	} {
+		r := records[i]
+		assert.Equal(t, int64(2), r.NumRows())
+		require.Equal(t, int64(10), r.NumCols())
+		schema := r.Schema()
+		assert.Equal(t, "result", schema.Field(0).Name)
+		assert.Equal(t, arrow.BinaryTypes.String, schema.Field(0).Type)
+		assert.Equal(t, "table", schema.Field(1).Name)
+		assert.Equal(t, arrow.PrimitiveTypes.Int64, schema.Field(1).Type)
+		assert.Equal(t, "_time", schema.Field(4).Name)
+		assert.Equal(t, arrow.FixedWidthTypes.Timestamp_ns, schema.Field(4).Type)
+		assert.Equal(t, "_value", schema.Field(5).Name)
+		assert.Equal(t, valueType, schema.Field(5).Type)
+		assert.Equal(t, "_result", r.Column(0).(*array.String).Value(0))
+		assert.Equal(t, int64(i), r.Column(1).(*array.Int64).Value(1))
+	}
+	assert.Equal(t, []float64{1.4, 6.6}, records[0].Column(5).(*array.Float64).Float64Values())
+	assert.Equal(t, []int64{4, -1}, records[1].Column(5).(*array.Int64).Int64Values())
+	assert.Equal(t, arrow.Timestamp(1582022048135814545), records[0].Column(4).(*array.Timestamp).Value(0))
+}
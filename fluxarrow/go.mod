@@ -0,0 +1,11 @@
+module github.com/bonitoo-io/influxdb-client-go/fluxarrow
+
+go 1.15
+
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20211112161151-bc219186db40
+	github.com/bonitoo-io/influxdb-client-go v0.0.0
+	github.com/stretchr/testify v1.7.0
+)
+
+replace github.com/bonitoo-io/influxdb-client-go => ../
@@ -10,6 +10,13 @@ import (
 	"sync"
 )
 
+// writerPool holds gzip writers reused by CompressWithGzip to reduce allocations
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
 type ReadWaitCloser struct {
 	pipeReader *io.PipeReader
 	wg         sync.WaitGroup
@@ -24,11 +31,13 @@ func (r *ReadWaitCloser) Close() error {
 // CompressWithGzip takes an io.Reader as input and pipes
 // it through a gzip.Writer returning an io.Reader containing
 // the gzipped data.
-// An error is returned if passing data to the gzip.Writer fails
+// An error is returned if passing data to the gzip.Writer fails.
+// gzip.Writer is taken from a pool and returned back when compression is finished
 // this is shamelessly stolen from https://github.com/influxdata/telegraf
 func CompressWithGzip(data io.Reader) (io.ReadCloser, error) {
 	pipeReader, pipeWriter := io.Pipe()
-	gzipWriter := gzip.NewWriter(pipeWriter)
+	gzipWriter := writerPool.Get().(*gzip.Writer)
+	gzipWriter.Reset(pipeWriter)
 
 	rc := &ReadWaitCloser{
 		pipeReader: pipeReader,
@@ -39,6 +48,7 @@ func CompressWithGzip(data io.Reader) (io.ReadCloser, error) {
 	go func() {
 		_, err = io.Copy(gzipWriter, data)
 		gzipWriter.Close()
+		writerPool.Put(gzipWriter)
 		// subsequent reads from the read half of the pipe will
 		// return no bytes and the error err, or EOF if err is nil.
 		pipeWriter.CloseWithError(err)
@@ -7,7 +7,11 @@ package gzip_test
 import (
 	"bytes"
 	egzip "compress/gzip"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/bonitoo-io/influxdb-client-go/internal/gzip"
@@ -32,3 +36,65 @@ func TestGzip(t *testing.T) {
 		t.Fatal("text did not encode or possibly decode properly")
 	}
 }
+
+func TestGzipPooledWriters(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			text := strings.Repeat(fmt.Sprintf("test,id=%d f=%di 1\n", i, i), i*50+1)
+			r, err := gzip.CompressWithGzip(strings.NewReader(text))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ur, err := egzip.NewReader(r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res, err := ioutil.ReadAll(ur)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(res) != text {
+				t.Errorf("text %d did not encode or possibly decode properly", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+var benchmarkData = strings.Repeat("test,hostname=host_1,id=rack_1,vendor=AWS disk_free=886.5419768959645,disk_total=1000000i,mem_free=3929322399955161446u 1586043600000000000\n", 1000)
+
+func BenchmarkCompressWithGzip(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r, err := gzip.CompressWithGzip(strings.NewReader(benchmarkData))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCompressWithNewWriter is baseline allocating gzip.Writer per batch
+func BenchmarkCompressWithNewWriter(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		pipeReader, pipeWriter := io.Pipe()
+		go func() {
+			gzipWriter := egzip.NewWriter(pipeWriter)
+			_, err := io.Copy(gzipWriter, strings.NewReader(benchmarkData))
+			gzipWriter.Close()
+			pipeWriter.CloseWithError(err)
+		}()
+		if _, err := io.Copy(ioutil.Discard, pipeReader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+// Logger is the interface for logging client diagnostic messages, e.g. retries of writes and sending of batches.
+// Set it via Options.SetLogger to route messages into an application logger.
+// Filtering by Options.LogLevel is applied only by the default logger, a custom logger receives all messages
+type Logger interface {
+	// Debugf logs debug message, e.g. content of written batch
+	Debugf(format string, v ...interface{})
+	// Infof logs informational message
+	Infof(format string, v ...interface{})
+	// Warnf logs warning, e.g. retrying of a failed write
+	Warnf(format string, v ...interface{})
+	// Errorf logs error
+	Errorf(format string, v ...interface{})
+}
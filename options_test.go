@@ -0,0 +1,84 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPrecisionFromString(t *testing.T) {
+	opts := DefaultOptions()
+	for s, p := range map[string]time.Duration{
+		"s":  time.Second,
+		"ms": time.Millisecond,
+		"us": time.Microsecond,
+		"ns": time.Nanosecond,
+	} {
+		require.Nil(t, opts.SetPrecisionFromString(s))
+		assert.Equal(t, p, opts.Precision())
+		assert.Equal(t, s, opts.WritePrecision().String())
+	}
+
+	opts.SetPrecision(time.Millisecond)
+	err := opts.SetPrecisionFromString("m")
+	require.NotNil(t, err)
+	assert.Equal(t, `unknown precision "m", expected one of ns, us, ms, s`, err.Error())
+	// precision is kept untouched
+	assert.Equal(t, time.Millisecond, opts.Precision())
+}
+
+// testLogger collects formatted messages prefixed by level
+type testLogger struct {
+	messages []string
+	lock     sync.Mutex
+}
+
+func (l *testLogger) log(level, format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.messages = append(l.messages, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{}) { l.log("D", format, v...) }
+func (l *testLogger) Infof(format string, v ...interface{})  { l.log("I", format, v...) }
+func (l *testLogger) Warnf(format string, v ...interface{})  { l.log("W", format, v...) }
+func (l *testLogger) Errorf(format string, v ...interface{}) { l.log("E", format, v...) }
+
+func TestCustomLogger(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetOutput(&logOutput)
+	defer log.SetOutput(os.Stderr)
+	logger := &testLogger{}
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetLogger(logger).SetRetryInterval(10000)
+	assert.Equal(t, logger, client.options.Logger())
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "service down"})
+	service := newWriteService("my-org", "my-bucket", client)
+
+	err := service.handleWrite(context.Background(), &batch{batch: "test f=1i 1\n"})
+	require.NotNil(t, err)
+	assert.Contains(t, logger.messages, "D Writing batch: test f=1i 1\n")
+	assert.Contains(t, logger.messages, "E Write error: unavailable: service down\nBatch kept for retrying\n")
+	// nothing is written to the standard logger
+	assert.Equal(t, "", logOutput.String())
+
+	// default logger writes to the standard logger
+	client.options.SetLogger(nil).SetLogLevel(1)
+	service.handleWrite(context.Background(), &batch{batch: "test f=1i 2\n"})
+	assert.Contains(t, logOutput.String(), "[W]! Write proc: cannot write yet, storing batch to queue")
+}
@@ -5,7 +5,12 @@
 package influxdb2
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	lp "github.com/influxdata/line-protocol"
@@ -43,17 +48,27 @@ func (m *Point) Time() time.Time {
 // SortTags orders the tags of a point alphanumerically by key.
 // This is just here as a helper, to make it easy to keep tags sorted if you are creating a Point manually.
 func (m *Point) SortTags() *Point {
-	sort.Slice(m.tags, func(i, j int) bool { return m.tags[i].Key < m.tags[j].Key })
+	return m.SortTagsFunc(func(a, b *lp.Tag) bool { return a.Key < b.Key })
+}
+
+// SortTagsFunc orders the tags of a point by the less function, e.g. by key length and then by key
+func (m *Point) SortTagsFunc(less func(a, b *lp.Tag) bool) *Point {
+	sort.SliceStable(m.tags, func(i, j int) bool { return less(m.tags[i], m.tags[j]) })
 	return m
 }
 
 // SortFields orders the fields of a point alphanumerically by key.
 func (m *Point) SortFields() *Point {
-	sort.Slice(m.fields, func(i, j int) bool { return m.fields[i].Key < m.fields[j].Key })
+	return m.SortFieldsFunc(func(a, b *lp.Field) bool { return a.Key < b.Key })
+}
+
+// SortFieldsFunc orders the fields of a point by the less function
+func (m *Point) SortFieldsFunc(less func(a, b *lp.Field) bool) *Point {
+	sort.SliceStable(m.fields, func(i, j int) bool { return less(m.fields[i], m.fields[j]) })
 	return m
 }
 
-// AddTag adds a tag to a point.
+// AddTag adds a tag to a point, value of existing tag with the same key is replaced.
 func (m *Point) AddTag(k, v string) *Point {
 	for i, tag := range m.tags {
 		if k == tag.Key {
@@ -65,11 +80,11 @@ func (m *Point) AddTag(k, v string) *Point {
 	return m
 }
 
-// AddField adds a field to a point.
+// AddField adds a field to a point, value of existing field with the same key is replaced.
 func (m *Point) AddField(k string, v interface{}) *Point {
 	for i, field := range m.fields {
 		if k == field.Key {
-			m.fields[i].Value = v
+			m.fields[i].Value = convertField(v)
 			return m
 		}
 	}
@@ -77,11 +92,43 @@ func (m *Point) AddField(k string, v interface{}) *Point {
 	return m
 }
 
+// SetMeasurement sets the name of measurement of a point.
+func (m *Point) SetMeasurement(measurement string) *Point {
+	m.measurement = measurement
+	return m
+}
+
 // Name returns the name of measurement of a point.
 func (m *Point) Name() string {
 	return m.measurement
 }
 
+// MarshalJSON encodes point as JSON object with measurement, tags, fields and time, if set
+func (m *Point) MarshalJSON() ([]byte, error) {
+	p := struct {
+		Measurement string                 `json:"measurement"`
+		Tags        map[string]string      `json:"tags,omitempty"`
+		Fields      map[string]interface{} `json:"fields"`
+		Time        *time.Time             `json:"time,omitempty"`
+	}{
+		Measurement: m.measurement,
+		Fields:      make(map[string]interface{}, len(m.fields)),
+	}
+	if len(m.tags) > 0 {
+		p.Tags = make(map[string]string, len(m.tags))
+		for _, t := range m.tags {
+			p.Tags[t.Key] = t.Value
+		}
+	}
+	for _, f := range m.fields {
+		p.Fields[f.Key] = f.Value
+	}
+	if !m.timestamp.IsZero() {
+		p.Time = &m.timestamp
+	}
+	return json.Marshal(p)
+}
+
 // NewPointWithMeasurement creates a empty Point
 // Use AddTag and AddField to fill point with data
 func NewPointWithMeasurement(measurement string) *Point {
@@ -123,6 +170,142 @@ func NewPoint(
 	return m
 }
 
+// FieldType is line protocol type of a field value
+type FieldType int
+
+// Field types of NewPointWithSchema
+const (
+	FieldTypeString FieldType = iota
+	FieldTypeFloat
+	FieldTypeInteger
+	FieldTypeUnsigned
+	FieldTypeBool
+)
+
+// String returns name of the field type
+func (t FieldType) String() string {
+	switch t {
+	case FieldTypeString:
+		return "string"
+	case FieldTypeFloat:
+		return "float"
+	case FieldTypeInteger:
+		return "integer"
+	case FieldTypeUnsigned:
+		return "unsigned"
+	case FieldTypeBool:
+		return "bool"
+	}
+	return fmt.Sprintf("FieldType(%d)", int(t))
+}
+
+// NewPointWithSchema creates a Point from measurement name, tags, fields with string values and a timestamp.
+// Field values are parsed into types given by schema, fields missing in schema are kept as strings.
+// Returns error if a value cannot be parsed into its type
+func NewPointWithSchema(
+	measurement string,
+	tags map[string]string,
+	fields map[string]string,
+	schema map[string]FieldType,
+	ts time.Time,
+) (*Point, error) {
+	typed := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		value, err := parseFieldValue(v, schema[k])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", k, err)
+		}
+		typed[k] = value
+	}
+	return NewPoint(measurement, tags, typed, ts), nil
+}
+
+// parseFieldValue parses string s into value of the field type t
+func parseFieldValue(s string, t FieldType) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch t {
+	case FieldTypeString:
+		return s, nil
+	case FieldTypeFloat:
+		value, err = strconv.ParseFloat(s, 64)
+	case FieldTypeInteger:
+		value, err = strconv.ParseInt(s, 10, 64)
+	case FieldTypeUnsigned:
+		value, err = strconv.ParseUint(s, 10, 64)
+	case FieldTypeBool:
+		value, err = strconv.ParseBool(s)
+	default:
+		return nil, fmt.Errorf("unknown field type %s", t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q as %s", s, t)
+	}
+	return value, nil
+}
+
+// nonFiniteFieldRegexp matches field with NaN or infinite float value, which is not supported by line protocol, in the field set
+var nonFiniteFieldRegexp = regexp.MustCompile(`(?:^|,)([^ ,=]+)=([+-]?(?i:nan|inf|infinity))(?:[ ,]|$)`)
+
+// fieldSet returns part of the line protocol line following the measurement and tags, i.e. after the first unescaped space.
+// Returns empty string if the line has no field set
+func fieldSet(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ' ':
+			return line[i+1:]
+		}
+	}
+	return ""
+}
+
+// ParsePoints creates Points from line protocol lines separated by new line char, with timestamps in the given precision.
+// Lines without timestamp produce points without time.
+// In case of invalid lines, e.g. containing NaN or infinite float values, error describing each invalid line is returned
+// together with points of all valid lines, so invalid lines can be skipped
+func ParsePoints(lines string, precision time.Duration) ([]*Point, error) {
+	handler := lp.NewMetricHandler()
+	handler.SetTimePrecision(precision)
+	parser := lp.NewParser(handler)
+	// keep missing timestamp empty, so server sets it
+	parser.SetTimeFunc(func() time.Time {
+		return time.Time{}
+	})
+	var points []*Point
+	var errs []string
+	for i, line := range strings.Split(lines, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		metrics, err := parser.Parse([]byte(line))
+		if err != nil {
+			if m := nonFiniteFieldRegexp.FindStringSubmatch(fieldSet(line)); m != nil {
+				errs = append(errs, fmt.Sprintf("line %d: field %s has non-finite float value %s", i+1, m[1], m[2]))
+			} else {
+				errs = append(errs, fmt.Sprintf("line %d: %s", i+1, err.Error()))
+			}
+			continue
+		}
+		for _, m := range metrics {
+			point := NewPointWithMeasurement(m.Name())
+			for _, t := range m.TagList() {
+				point.AddTag(t.Key, t.Value)
+			}
+			for _, f := range m.FieldList() {
+				point.AddField(f.Key, f.Value)
+			}
+			point.SetTime(m.Time())
+			points = append(points, point)
+		}
+	}
+	if len(errs) > 0 {
+		return points, fmt.Errorf("invalid line protocol: %s", strings.Join(errs, "; "))
+	}
+	return points, nil
+}
+
 // convertField converts any primitive type to types supported by line protocol
 func convertField(v interface{}) interface{} {
 	switch v := v.(type) {
@@ -6,6 +6,7 @@ package influxdb2
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -228,6 +229,92 @@ func TestPointAdd(t *testing.T) {
 	verifyPoint(t, p)
 }
 
+func TestPointChaining(t *testing.T) {
+	ts := time.Unix(60, 70)
+	p := NewPointWithMeasurement("air").
+		AddTag("location", "Prague").
+		AddTag("sensor", "a").
+		AddField("temperature", 22.5).
+		AddField("humidity", 55).
+		AddField("ok", true).
+		SetTime(time.Unix(1, 0)).
+		// re-setting updates values in place
+		AddTag("sensor", "b").
+		AddField("humidity", 56).
+		SetTime(ts)
+	expected := NewPoint("air",
+		map[string]string{"location": "Prague", "sensor": "b"},
+		map[string]interface{}{"temperature": 22.5, "humidity": 56, "ok": true},
+		ts)
+	p.SortTags().SortFields()
+	expected.SortTags().SortFields()
+	assert.Equal(t, expected.ToLineProtocol(time.Nanosecond), p.ToLineProtocol(time.Nanosecond))
+	assert.Equal(t, "air,location=Prague,sensor=b humidity=56i,ok=true,temperature=22.5 60000000070\n", p.ToLineProtocol(time.Nanosecond))
+	assert.Equal(t, int64(56), p.FieldList()[0].Value)
+}
+
+func TestPointSortFunc(t *testing.T) {
+	p := NewPointWithMeasurement("test").
+		AddTag("region", "eu").
+		AddTag("id", "10").
+		AddTag("host", "a").
+		AddTag("dc", "1").
+		AddField("value", 1).
+		AddField("a", 2).
+		AddField("max", 3).
+		SetTime(time.Unix(60, 70))
+	byLength := func(a, b string) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	}
+	p.SortTagsFunc(func(a, b *lp.Tag) bool { return byLength(a.Key, b.Key) })
+	p.SortFieldsFunc(func(a, b *lp.Field) bool { return byLength(a.Key, b.Key) })
+	assert.Equal(t, "test,dc=1,id=10,host=a,region=eu a=2i,max=3i,value=1i 60000000070\n", p.ToLineProtocol(time.Nanosecond))
+
+	p.SortTags().SortFields()
+	assert.Equal(t, "test,dc=1,host=a,id=10,region=eu a=2i,max=3i,value=1i 60000000070\n", p.ToLineProtocol(time.Nanosecond))
+}
+
+func TestNewPointWithSchema(t *testing.T) {
+	schema := map[string]FieldType{
+		"count":   FieldTypeInteger,
+		"enabled": FieldTypeBool,
+		"load":    FieldTypeFloat,
+		"bytes":   FieldTypeUnsigned,
+	}
+	p, err := NewPointWithSchema("test", map[string]string{"id": "10"},
+		map[string]string{"count": "42", "enabled": "true", "load": "0.5", "bytes": "18446744073709551615", "name": "42"},
+		schema, time.Unix(60, 70))
+	require.Nil(t, err)
+	assert.Equal(t, `test,id=10 bytes=18446744073709551615u,count=42i,enabled=true,load=0.5,name="42" 60000000070`+"\n", p.ToLineProtocol(time.Nanosecond))
+
+	_, err = NewPointWithSchema("test", nil, map[string]string{"count": "4.2"}, schema, time.Unix(60, 70))
+	require.NotNil(t, err)
+	assert.Equal(t, `field count: cannot parse "4.2" as integer`, err.Error())
+
+	_, err = NewPointWithSchema("test", nil, map[string]string{"enabled": "yes"}, schema, time.Unix(60, 70))
+	require.NotNil(t, err)
+	assert.Equal(t, `field enabled: cannot parse "yes" as bool`, err.Error())
+
+	_, err = NewPointWithSchema("test", nil, map[string]string{"f": "1"}, map[string]FieldType{"f": FieldType(10)}, time.Unix(60, 70))
+	require.NotNil(t, err)
+	assert.Equal(t, `field f: unknown field type FieldType(10)`, err.Error())
+}
+
+func TestPointSetMeasurement(t *testing.T) {
+	p := NewPointWithMeasurement("test").
+		AddTag("id", "10").
+		AddField("float64", 80.1).
+		SetTime(time.Unix(60, 70))
+	assert.Equal(t, "test,id=10 float64=80.1 60000000070\n", p.ToLineProtocol(time.Nanosecond))
+
+	p.SetMeasurement("ns_test")
+	assert.Equal(t, "ns_test", p.Name())
+	assert.Equal(t, "ns_test,id=10 float64=80.1 60000000070\n", p.ToLineProtocol(time.Nanosecond))
+}
+
 func TestPrecision(t *testing.T) {
 	p := NewPointWithMeasurement("test")
 	p.AddTag("id", "10")
@@ -295,3 +382,77 @@ func BenchmarkPointStringMulti(b *testing.B) {
 		s = buff.String()
 	}
 }
+
+func BenchmarkEncodePointsSingle(b *testing.B) {
+	service := newWriteService("my-org", "my-bucket", &testClient{options: DefaultOptions()})
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		for _, p := range points[:100] {
+			s, _ = service.encodePoints(p)
+		}
+	}
+}
+
+func BenchmarkEncodePointsMulti(b *testing.B) {
+	service := newWriteService("my-org", "my-bucket", &testClient{options: DefaultOptions()})
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		s, _ = service.encodePoints(points[:100]...)
+	}
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = points[i].ToLineProtocol(time.Nanosecond)
+	}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		s = buffer(lines)
+	}
+}
+
+func TestParsePoints(t *testing.T) {
+	lines := "test,id=10 f=1.5,i=2i,s=\"a\" 60\n\ntest,id=11 b=true\n"
+	points, err := ParsePoints(lines, time.Second)
+	require.Nil(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, "test,id=10 f=1.5,i=2i,s=\"a\" 60000000000\n", points[0].ToLineProtocol(time.Nanosecond))
+	assert.Equal(t, time.Unix(60, 0), points[0].Time())
+	assert.True(t, points[1].Time().IsZero())
+	assert.Equal(t, true, points[1].FieldList()[0].Value)
+
+	lines = "test,id=10 f=1.5 60\ntest,id=11 f=NaN 60\ntest,id=12 g=2,f=-Inf\ntest f=\ntest,id=13 f=3 60"
+	points, err = ParsePoints(lines, time.Second)
+	require.NotNil(t, err)
+	assert.Equal(t, `invalid line protocol: line 2: field f has non-finite float value NaN; `+
+		`line 3: field f has non-finite float value -Inf; `+
+		`line 4: metric parse error: expected field at 1:8: "test f="`, err.Error())
+	// valid lines are parsed
+	require.Len(t, points, 2)
+	assert.Equal(t, "test,id=10 f=1.5 60\n", points[0].ToLineProtocol(time.Second))
+	assert.Equal(t, "test,id=13 f=3 60\n", points[1].ToLineProtocol(time.Second))
+
+	// tags with non-finite looking values are not reported as fields
+	lines = "test,host=nan f=\ntest,host=inf,h\\ x=nan f=NaN 60"
+	_, err = ParsePoints(lines, time.Second)
+	require.NotNil(t, err)
+	assert.Equal(t, `invalid line protocol: line 1: metric parse error: expected field at 1:17: "test,host=nan f="; `+
+		`line 2: field f has non-finite float value NaN`, err.Error())
+}
+
+func TestPointMarshalJSON(t *testing.T) {
+	p := NewPoint("test",
+		map[string]string{"id": "10ad=", "ven=dor": "AWS"},
+		map[string]interface{}{"float": 80.1234567, "int": -1234567890, "uint": uint(12), "bool": false, "string": "six"},
+		time.Unix(60, 70).UTC())
+	b, err := json.Marshal(p)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"measurement":"test","tags":{"id":"10ad=","ven=dor":"AWS"},
+		"fields":{"bool":false,"float":80.1234567,"int":-1234567890,"string":"six","uint":12},
+		"time":"1970-01-01T00:01:00.00000007Z"}`, string(b))
+
+	b, err = json.Marshal(NewPointWithMeasurement("empty").AddField("f", 1))
+	require.Nil(t, err)
+	assert.Equal(t, `{"measurement":"empty","fields":{"f":1}}`, string(b))
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import "time"
+
+// Precision is precision of timestamps of written data
+type Precision int
+
+// Supported precisions of timestamps
+const (
+	PrecisionNanosecond Precision = iota
+	PrecisionMicrosecond
+	PrecisionMillisecond
+	PrecisionSecond
+)
+
+// String returns precision as used in write request URL: ns, us, ms or s
+func (p Precision) String() string {
+	switch p {
+	case PrecisionMicrosecond:
+		return "us"
+	case PrecisionMillisecond:
+		return "ms"
+	case PrecisionSecond:
+		return "s"
+	default:
+		return "ns"
+	}
+}
+
+// Duration returns precision as a time unit
+func (p Precision) Duration() time.Duration {
+	switch p {
+	case PrecisionMicrosecond:
+		return time.Microsecond
+	case PrecisionMillisecond:
+		return time.Millisecond
+	case PrecisionSecond:
+		return time.Second
+	default:
+		return time.Nanosecond
+	}
+}
+
+// precisionFromDuration returns Precision of the time unit. Returns false if duration is not a supported precision
+func precisionFromDuration(d time.Duration) (Precision, bool) {
+	switch d {
+	case time.Nanosecond:
+		return PrecisionNanosecond, true
+	case time.Microsecond:
+		return PrecisionMicrosecond, true
+	case time.Millisecond:
+		return PrecisionMillisecond, true
+	case time.Second:
+		return PrecisionSecond, true
+	}
+	return PrecisionNanosecond, false
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecisionType(t *testing.T) {
+	p := NewPoint("test", nil, map[string]interface{}{"f": 1}, time.Unix(60, 123456789))
+	for _, test := range []struct {
+		precision Precision
+		str       string
+		duration  time.Duration
+		line      string
+	}{
+		{PrecisionNanosecond, "ns", time.Nanosecond, "test f=1i 60123456789\n"},
+		{PrecisionMicrosecond, "us", time.Microsecond, "test f=1i 60123456\n"},
+		{PrecisionMillisecond, "ms", time.Millisecond, "test f=1i 60123\n"},
+		{PrecisionSecond, "s", time.Second, "test f=1i 60\n"},
+	} {
+		assert.Equal(t, test.str, test.precision.String())
+		assert.Equal(t, test.duration, test.precision.Duration())
+
+		client := NewClientWithOptions("http://localhost:9999", "a", DefaultOptions().SetWritePrecision(test.precision))
+		service := newWriteService("my-org", "my-bucket", client)
+		wUrl, err := service.writeUrl()
+		require.Nil(t, err)
+		assert.Equal(t, "http://localhost:9999/api/v2/write?bucket=my-bucket&org=my-org&precision="+test.str, wUrl)
+		line, err := service.encodePoints(p)
+		require.Nil(t, err)
+		assert.Equal(t, test.line, line)
+
+		// time.Duration shim
+		opts := DefaultOptions().SetPrecision(test.duration)
+		assert.Equal(t, test.precision, opts.WritePrecision())
+		assert.Equal(t, test.duration, opts.Precision())
+	}
+
+	opts := DefaultOptions().SetPrecision(time.Minute)
+	assert.Equal(t, PrecisionNanosecond, opts.WritePrecision())
+	assert.Equal(t, time.Nanosecond, opts.Precision())
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"strings"
+	"sync"
+)
+
+// WriteProgress holds totals of data successfully written by a write client
+type WriteProgress struct {
+	// Batches is count of written batches
+	Batches uint
+	// Points is count of written points and line protocol records
+	Points uint
+	// Bytes is size of written line protocol, before compression
+	Bytes uint
+}
+
+// WriteProgressCallback is called periodically with actual totals of written data, e.g. to show progress of a bulk backfill
+type WriteProgressCallback func(progress WriteProgress)
+
+// progressTracker sums data written by a write service and calls callback each time
+// at least everyBatches batches or everyPoints points were written since the last call
+type progressTracker struct {
+	callback     WriteProgressCallback
+	everyBatches uint
+	everyPoints  uint
+	progress     WriteProgress
+	reported     WriteProgress
+	lock         sync.Mutex
+}
+
+// set sets callback and reporting intervals. Zero interval is not applied, both zero mean to report each batch
+func (p *progressTracker) set(callback WriteProgressCallback, everyBatches, everyPoints uint) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.callback = callback
+	p.everyBatches = everyBatches
+	p.everyPoints = everyPoints
+}
+
+// add counts written batch and calls callback if reporting interval is reached
+func (p *progressTracker) add(batch string) {
+	p.lock.Lock()
+	p.progress.Batches++
+	p.progress.Points += uint(strings.Count(batch, "\n"))
+	p.progress.Bytes += uint(len(batch))
+	report := p.callback != nil &&
+		((p.everyBatches == 0 && p.everyPoints == 0) ||
+			(p.everyBatches > 0 && p.progress.Batches-p.reported.Batches >= p.everyBatches) ||
+			(p.everyPoints > 0 && p.progress.Points-p.reported.Points >= p.everyPoints))
+	callback, progress := p.callback, p.progress
+	if report {
+		p.reported = progress
+	}
+	p.lock.Unlock()
+	// callback is called without lock to allow it to use the write client
+	if report {
+		callback(progress)
+	}
+}
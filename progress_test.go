@@ -0,0 +1,73 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProgress(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(10)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	var lock sync.Mutex
+	var reports []WriteProgress
+	writeApi.SetProgressCallback(func(progress WriteProgress) {
+		lock.Lock()
+		defer lock.Unlock()
+		reports = append(reports, progress)
+	}, 0, 25)
+	points := genPoints(100)
+	for _, p := range points {
+		require.Nil(t, writeApi.WritePoint(p))
+	}
+	writeApi.Close()
+	require.Len(t, client.Lines(), 100)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, reports, 3)
+	for i, r := range reports {
+		assert.Equal(t, uint(3*(i+1)), r.Batches)
+		assert.Equal(t, uint(30*(i+1)), r.Points)
+		if i > 0 {
+			assert.True(t, r.Bytes > reports[i-1].Bytes)
+		}
+	}
+}
+
+func TestWriteProgressBlocking(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	var reports []WriteProgress
+	writeApi.SetProgressCallback(func(progress WriteProgress) {
+		reports = append(reports, progress)
+	}, 2, 0)
+	for i := 0; i < 5; i++ {
+		require.Nil(t, writeApi.WriteRecord(context.Background(), "test,a=1 f=1i 1", "test,a=2 f=2i 2"))
+	}
+	assert.Equal(t, []WriteProgress{{Batches: 2, Points: 4, Bytes: 64}, {Batches: 4, Points: 8, Bytes: 128}}, reports)
+
+	// failed writes are not counted
+	client.SetReplyError(&Error{StatusCode: 400, Code: "invalid", Message: "data"})
+	require.NotNil(t, writeApi.WriteRecord(context.Background(), "test,a=1 f=1i 1"))
+	client.SetReplyError(nil)
+	writeApi.SetProgressCallback(func(progress WriteProgress) {
+		reports = append(reports, progress)
+	}, 0, 0)
+	require.Nil(t, writeApi.WriteRecord(context.Background(), "test,a=1 f=1i 1"))
+	assert.Equal(t, WriteProgress{Batches: 6, Points: 11, Bytes: 176}, reports[2])
+}
@@ -17,11 +17,16 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bonitoo-io/influxdb-client-go/domain"
 )
@@ -38,14 +43,61 @@ const (
 	timeDatatypeRFCNano  = "dateTime:RFC3339Nano"
 )
 
+// Timestamp formats of flux query response, accepted by the server in Dialect.DateTimeFormat.
+// These are the only formats the server supports, it cannot return epoch timestamps
+const (
+	DateTimeFormatRFC3339     = "RFC3339"
+	DateTimeFormatRFC3339Nano = "RFC3339Nano"
+)
+
 // QueryApi provides methods for performing synchronously flux query against InfluxDB server
 type QueryApi interface {
 	// QueryRaw executes flux query on the InfluxDB server and returns complete query result as a string with table annotations according to dialect
 	QueryRaw(ctx context.Context, query string, dialect *domain.Dialect) (string, error)
+	// QueryRawWithHeaders executes flux query same as QueryRaw, and returns also headers of the server response,
+	// e.g. request id or rate limit information
+	QueryRawWithHeaders(ctx context.Context, query string, dialect *domain.Dialect) (string, http.Header, error)
+	// QueryRawBytes executes flux query same as QueryRaw, and returns the result as bytes without conversion to string
+	QueryRawBytes(ctx context.Context, query string, dialect *domain.Dialect) ([]byte, error)
 	// Query executes flux query on the InfluxDB server and returns QueryTableResult which parses streamed response into structures representing flux table parts
 	Query(ctx context.Context, query string) (*QueryTableResult, error)
+	// QueryWithDialect executes flux query same as Query, with the result formatted according to dialect, e.g. with RFC3339Nano timestamps,
+	// other delimiter or a subset of annotations. Without datatype annotation all values are parsed as strings.
+	// Dialect must have header, nil dialect means DefaultDialect
+	QueryWithDialect(ctx context.Context, query string, dialect *domain.Dialect) (*QueryTableResult, error)
+	// QueryWithTimeout executes flux query same as Query, but additionally asks the server to abort the query execution
+	// when it runs longer than serverTimeout. Zero or negative serverTimeout means no server-side limit
+	QueryWithTimeout(ctx context.Context, query string, serverTimeout time.Duration) (*QueryTableResult, error)
+	// QueryFirst executes flux query and decodes the first record of the result into the struct pointed to by dest, see FluxRecord.Decode.
+	// Rest of the result is discarded. Returns error if the result has no records
+	QueryFirst(ctx context.Context, query string, dest interface{}) error
+	// QueryAll executes flux query and decodes all records of the result into the slice pointed to by dest, see FluxRecord.Decode.
+	// Slice elements must be structs or pointers to structs, decoded records are appended to the slice.
+	// Whole result is held in memory, it is meant for small results
+	QueryAll(ctx context.Context, query string, dest interface{}) error
+	// QueryWithParams executes flux query same as Query, with params passed to the query in the extern block as record v,
+	// e.g. v.bucket. Supported param values are strings, numbers, booleans, time.Time, time.Duration,
+	// slices (encoded as flux arrays) and maps with string keys (encoded as flux records)
+	QueryWithParams(ctx context.Context, query string, params map[string]interface{}) (*QueryTableResult, error)
+	// QueryTemplate executes flux query created from template by replacing {{name}} placeholders with values of args
+	// as escaped flux string literals, including quotes, e.g. filter(fn: (r) => r.host == {{host}}).
+	// Placeholders are meant for string values only and cannot be used for other flux types or parts of query code.
+	// Returns error if template contains placeholder without value in args
+	QueryTemplate(ctx context.Context, template string, args map[string]string) (*QueryTableResult, error)
+	// ExportPerTable executes flux query and writes each table of the result as CSV into a separate file table_<position>.csv
+	// in the directory dirPath. Result is streamed, only the actual table is written at a time. Returns paths of created files
+	ExportPerTable(ctx context.Context, query string, dirPath string) ([]string, error)
+	// TagValues returns distinct values of the tag tagKey in the bucket, using schema.tagValues flux function.
+	// Non-empty measurement limits the values to series of the measurement
+	TagValues(ctx context.Context, bucket, measurement, tagKey string) ([]string, error)
+	// Validate checks syntax of flux query by parsing it on the server, without executing it.
+	// Returns error describing parsing errors if the query is invalid
+	Validate(ctx context.Context, query string) error
 }
 
+// queryTimeoutHeader is the request header carrying server-side query execution limit
+const queryTimeoutHeader = "X-Influx-Query-Timeout"
+
 // queryApiImpl implements QueryApi interface
 type queryApiImpl struct {
 	org    string
@@ -55,39 +107,56 @@ type queryApiImpl struct {
 }
 
 func (q *queryApiImpl) QueryRaw(ctx context.Context, query string, dialect *domain.Dialect) (string, error) {
+	body, _, err := q.QueryRawWithHeaders(ctx, query, dialect)
+	return body, err
+}
+
+func (q *queryApiImpl) QueryRawWithHeaders(ctx context.Context, query string, dialect *domain.Dialect) (string, http.Header, error) {
+	body, headers, err := q.queryRaw(ctx, query, dialect)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(body), headers, nil
+}
+
+func (q *queryApiImpl) QueryRawBytes(ctx context.Context, query string, dialect *domain.Dialect) ([]byte, error) {
+	body, _, err := q.queryRaw(ctx, query, dialect)
+	return body, err
+}
+
+// queryRaw performs flux query with given dialect and returns whole response body and response headers
+func (q *queryApiImpl) queryRaw(ctx context.Context, query string, dialect *domain.Dialect) ([]byte, http.Header, error) {
 	queryUrl, err := q.queryUrl()
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 	queryType := "flux"
 	qr := domain.Query{Query: query, Type: &queryType, Dialect: dialect}
 	qrJson, err := json.Marshal(qr)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	var body string
-	perror := q.client.postRequest(ctx, queryUrl, bytes.NewReader(qrJson), func(req *http.Request) {
+	var body []byte
+	var headers http.Header
+	perror := q.postQuery(ctx, queryUrl, qrJson, func(req *http.Request) {
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Accept-Encoding", "gzip")
 	},
 		func(resp *http.Response) error {
+			headers = resp.Header
 			if resp.Header.Get("Content-Encoding") == "gzip" {
 				resp.Body, err = gzip.NewReader(resp.Body)
 				if err != nil {
 					return err
 				}
 			}
-			respBody, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return err
-			}
-			body = string(respBody)
-			return nil
+			body, err = ioutil.ReadAll(resp.Body)
+			return err
 		})
 	if perror != nil {
-		return "", perror
+		return nil, nil, perror
 	}
-	return body, nil
+	return body, headers, nil
 }
 
 // DefaultDialect return flux query Dialect with full annotations (datatype, group, default), header and comma char as a delimiter
@@ -102,21 +171,280 @@ func DefaultDialect() *domain.Dialect {
 	}
 }
 
+// DialectWithDateTimeFormat returns DefaultDialect with the given timestamp format, DateTimeFormatRFC3339 or DateTimeFormatRFC3339Nano.
+// There is no epoch format, the server rejects other values of Dialect.DateTimeFormat. Epoch timestamps can be computed
+// by the query itself instead, e.g. |> map(fn: (r) => ({r with _time: int(v: r._time)}))
+func DialectWithDateTimeFormat(dateTimeFormat string) *domain.Dialect {
+	dialect := DefaultDialect()
+	dialect.DateTimeFormat = &dateTimeFormat
+	return dialect
+}
+
 func (q *queryApiImpl) Query(ctx context.Context, query string) (*QueryTableResult, error) {
+	return q.query(ctx, query, DefaultDialect(), nil, nil)
+}
+
+func (q *queryApiImpl) QueryWithDialect(ctx context.Context, query string, dialect *domain.Dialect) (*QueryTableResult, error) {
+	if dialect == nil {
+		dialect = DefaultDialect()
+	}
+	if dialect.Header != nil && !*dialect.Header {
+		return nil, errors.New("dialect without header is not supported, column names are required for parsing the result, use QueryRaw instead")
+	}
+	return q.query(ctx, query, dialect, nil, nil)
+}
+
+func (q *queryApiImpl) QueryWithTimeout(ctx context.Context, query string, serverTimeout time.Duration) (*QueryTableResult, error) {
+	var requestCallback RequestCallback
+	if serverTimeout > 0 {
+		requestCallback = func(req *http.Request) {
+			req.Header.Set(queryTimeoutHeader, serverTimeout.String())
+		}
+	}
+	return q.query(ctx, query, DefaultDialect(), nil, requestCallback)
+}
+
+func (q *queryApiImpl) QueryWithParams(ctx context.Context, query string, params map[string]interface{}) (*QueryTableResult, error) {
+	extern, err := paramsExtern(params)
+	if err != nil {
+		return nil, err
+	}
+	return q.query(ctx, query, DefaultDialect(), extern, nil)
+}
+
+func (q *queryApiImpl) QueryTemplate(ctx context.Context, template string, args map[string]string) (*QueryTableResult, error) {
+	query, err := executeTemplate(template, args)
+	if err != nil {
+		return nil, err
+	}
+	return q.Query(ctx, query)
+}
+
+func (q *queryApiImpl) QueryFirst(ctx context.Context, query string, dest interface{}) error {
+	result, err := q.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	if !result.Next() {
+		if result.Err() != nil {
+			return result.Err()
+		}
+		return errors.New("query result has no records")
+	}
+	if err := result.Record().Decode(dest); err != nil {
+		result.Close()
+		return err
+	}
+	return result.Close()
+}
+
+func (q *queryApiImpl) QueryAll(ctx context.Context, query string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return errors.New("destination must be a non-nil pointer to a slice")
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New("destination slice elements must be structs or pointers to structs")
+	}
+	result, err := q.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	for result.Next() {
+		elem := reflect.New(elemType)
+		if err := result.Record().Decode(elem.Interface()); err != nil {
+			return err
+		}
+		if isPtr {
+			slice = reflect.Append(slice, elem)
+		} else {
+			slice = reflect.Append(slice, elem.Elem())
+		}
+	}
+	if result.Err() != nil {
+		return result.Err()
+	}
+	v.Elem().Set(slice)
+	return nil
+}
+
+func (q *queryApiImpl) TagValues(ctx context.Context, bucket, measurement, tagKey string) ([]string, error) {
+	result, err := q.Query(ctx, tagValuesQuery(bucket, measurement, tagKey))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	values := make([]string, 0)
+	for result.Next() {
+		if v, ok := result.Record().ValueString(); ok {
+			values = append(values, v)
+		}
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+	return values, nil
+}
+
+// tagValuesQuery returns flux query listing values of the tag tagKey in the bucket, optionally limited to the measurement
+func tagValuesQuery(bucket, measurement, tagKey string) string {
+	var sb strings.Builder
+	sb.WriteString("import \"influxdata/influxdb/schema\"\n")
+	sb.WriteString(fmt.Sprintf("schema.tagValues(bucket: %s, tag: %s", fluxStringLiteral(bucket), fluxStringLiteral(tagKey)))
+	if measurement != "" {
+		sb.WriteString(fmt.Sprintf(", predicate: (r) => r._measurement == %s", fluxStringLiteral(measurement)))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (q *queryApiImpl) ExportPerTable(ctx context.Context, query string, dirPath string) ([]string, error) {
+	result, err := q.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	var files []string
+	var file *os.File
+	var writer *csv.Writer
+	// closeFile flushes and closes actual table file, keeping the first error
+	closeFile := func(err error) error {
+		if file == nil {
+			return err
+		}
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		file = nil
+		return err
+	}
+	var columns []*FluxColumn
+	for result.Next() {
+		if result.TableChanged() {
+			if err := closeFile(nil); err != nil {
+				return files, err
+			}
+			name := filepath.Join(dirPath, fmt.Sprintf("table_%d.csv", result.TablePosition()))
+			file, err = os.Create(name)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, name)
+			writer = csv.NewWriter(file)
+			columns = result.TableMetadata().Columns()
+			header := make([]string, len(columns))
+			for i, c := range columns {
+				header[i] = c.Name()
+			}
+			if err := writer.Write(header); err != nil {
+				return files, closeFile(err)
+			}
+		}
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = renderValue(result.Record().ValueByKey(c.Name()))
+		}
+		if err := writer.Write(row); err != nil {
+			return files, closeFile(err)
+		}
+	}
+	if err := closeFile(result.Err()); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+func (q *queryApiImpl) Validate(ctx context.Context, query string) error {
+	u, err := url.Parse(q.client.ServerUrl())
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, "/api/v2/query/ast")
+	body, err := json.Marshal(domain.LanguageRequest{Query: query})
+	if err != nil {
+		return err
+	}
+	var ast interface{}
+	perror := q.client.postRequest(ctx, u.String(), bytes.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	}, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&ast)
+	})
+	if perror != nil {
+		return perror
+	}
+	// servers may return parsing errors also as a part of the AST
+	if errs := astErrors(ast, nil); len(errs) > 0 {
+		return fmt.Errorf("invalid flux query: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// astErrors appends messages of errors attached to nodes of JSON encoded flux AST to errs
+func astErrors(node interface{}, errs []string) []string {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		// walk in stable order to report errors always in the same order
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := n[k]
+			if k == "errors" {
+				if list, ok := v.([]interface{}); ok {
+					for _, e := range list {
+						if e, ok := e.(map[string]interface{}); ok {
+							if msg, ok := e["msg"].(string); ok {
+								errs = append(errs, msg)
+							}
+						}
+					}
+				}
+				continue
+			}
+			errs = astErrors(v, errs)
+		}
+	case []interface{}:
+		for _, v := range n {
+			errs = astErrors(v, errs)
+		}
+	}
+	return errs
+}
+
+// query performs flux query with dialect and optional extern block and calls requestCallback, if set, to customize the request
+func (q *queryApiImpl) query(ctx context.Context, query string, dialect *domain.Dialect, extern *domain.File, requestCallback RequestCallback) (*QueryTableResult, error) {
 	var queryResult *QueryTableResult
 	queryUrl, err := q.queryUrl()
 	if err != nil {
 		return nil, err
 	}
 	queryType := "flux"
-	qr := domain.Query{Query: query, Type: &queryType, Dialect: DefaultDialect()}
+	qr := domain.Query{Query: query, Type: &queryType, Dialect: dialect, Extern: extern}
 	qrJson, err := json.Marshal(qr)
 	if err != nil {
 		return nil, err
 	}
-	perror := q.client.postRequest(ctx, queryUrl, bytes.NewReader(qrJson), func(req *http.Request) {
+	perror := q.postQuery(ctx, queryUrl, qrJson, func(req *http.Request) {
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Accept-Encoding", "gzip")
+		if requestCallback != nil {
+			requestCallback(req)
+		}
 	},
 		func(resp *http.Response) error {
 			if resp.Header.Get("Content-Encoding") == "gzip" {
@@ -127,6 +455,9 @@ func (q *queryApiImpl) Query(ctx context.Context, query string) (*QueryTableResu
 			}
 			csvReader := csv.NewReader(resp.Body)
 			csvReader.FieldsPerRecord = -1
+			if dialect.Delimiter != nil && *dialect.Delimiter != "" {
+				csvReader.Comma, _ = utf8.DecodeRuneInString(*dialect.Delimiter)
+			}
 			queryResult = &QueryTableResult{Closer: resp.Body, csvReader: csvReader}
 			return nil
 		})
@@ -136,6 +467,65 @@ func (q *queryApiImpl) Query(ctx context.Context, query string) (*QueryTableResu
 	return queryResult, nil
 }
 
+// postQuery sends query request and retries it on retryable error, if enabled by QueryMaxRetries
+func (q *queryApiImpl) postQuery(ctx context.Context, queryUrl string, body []byte, requestCallback RequestCallback, responseCallback ResponseCallback) *Error {
+	if q.client.Options().UseGZipQuery() {
+		compressed, err := compressQuery(body)
+		if err != nil {
+			q.client.Options().Logger().Warnf("Query body compression error: %s, sending uncompressed\n", err.Error())
+		} else {
+			body = compressed
+			origCallback := requestCallback
+			requestCallback = func(req *http.Request) {
+				req.Header.Set("Content-Encoding", "gzip")
+				if origCallback != nil {
+					origCallback(req)
+				}
+			}
+		}
+	}
+	retryInterval := time.Duration(q.client.Options().RetryInterval()) * time.Millisecond
+	for attempt := uint(0); ; attempt++ {
+		perror := q.client.postRequest(ctx, queryUrl, bytes.NewReader(body), requestCallback, responseCallback)
+		if perror == nil || attempt >= q.client.Options().QueryMaxRetries() || !isRetryableQueryError(perror) {
+			return perror
+		}
+		wait := retryInterval
+		if perror.RetryAfter > 0 {
+			wait = time.Duration(perror.RetryAfter) * time.Second
+		}
+		q.client.Options().Logger().Warnf("Query error: %s, retrying in %s\n", perror.Error(), wait)
+		select {
+		case <-ctx.Done():
+			return NewError(ctx.Err())
+		case <-time.After(wait):
+		}
+		retryInterval *= 2
+	}
+}
+
+// compressQuery returns gzip compressed query request body
+var compressQuery = func(body []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// isRetryableQueryError returns true if query failed with a temporary server error
+func isRetryableQueryError(perror *Error) bool {
+	switch perror.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
 func (q *queryApiImpl) queryUrl() (string, error) {
 	if q.url == "" {
 		u, err := url.Parse(q.client.ServerUrl())
@@ -145,6 +535,9 @@ func (q *queryApiImpl) queryUrl() (string, error) {
 		u.Path = path.Join(u.Path, "/api/v2/query")
 
 		params := u.Query()
+		for k, v := range q.client.Options().QueryParams() {
+			params.Set(k, v)
+		}
 		params.Set("org", q.org)
 		u.RawQuery = params.Encode()
 		q.lock.Lock()
@@ -166,7 +559,10 @@ type QueryTableResult struct {
 	tableChanged  bool
 	table         *FluxTableMetadata
 	record        *FluxRecord
-	err           error
+	headerOnly    bool
+	// rowNumber is count of CSV rows read so far
+	rowNumber int
+	err       error
 }
 
 // TablePosition returns actual flux table position in the result.
@@ -195,6 +591,16 @@ func (q *QueryTableResult) Record() *FluxRecord {
 	return q.record
 }
 
+// Decode stores values of the last parsed record into the struct pointed to by target.
+// Struct fields are matched to columns the same way as by FluxRecord.Decode, e.g. by the `influxdb:"_value"` tag.
+// Returns error if there is no record, a tagged column is missing in the record or its value doesn't match the field type
+func (q *QueryTableResult) Decode(target interface{}) error {
+	if q.record == nil {
+		return errors.New("no record to decode, call Next() first")
+	}
+	return q.record.Decode(target)
+}
+
 type parsingState int
 
 const (
@@ -212,11 +618,12 @@ func (q *QueryTableResult) Next() bool {
 	// set closing query in case of preliminary return
 	closer := func() {
 		if err := q.Close(); err != nil {
-			message := err.Error()
 			if q.err != nil {
-				message = fmt.Sprintf("%s,%s", message, q.err.Error())
+				// keep parsing or query error available to errors.As
+				q.err = fmt.Errorf("%s,%w", err.Error(), q.err)
+			} else {
+				q.err = err
 			}
-			q.err = errors.New(message)
 		}
 	}
 	defer func() {
@@ -230,90 +637,308 @@ readRow:
 		q.err = nil
 		return false
 	}
+	q.rowNumber++
 	if q.err != nil {
+		var csvErr *csv.ParseError
+		if errors.As(q.err, &csvErr) {
+			q.err = &QueryParseError{Row: q.rowNumber, Raw: row, Err: q.err}
+		} else {
+			q.err = NewError(q.err)
+		}
 		return false
 	}
 
 	if len(row) <= 1 {
 		goto readRow
 	}
-	switch row[0] {
-	case "":
-		if parsingState == parsingStateError {
-			var message string
-			if len(row) > 1 {
-				message = row[1]
-			} else {
-				message = "unknown query error"
-			}
-			reference := ""
-			if len(row) > 2 && len(row[2]) > 0 {
-				reference = fmt.Sprintf(",%s", row[2])
+	// dialect without annotations doesn't have the leading annotation column, first row is header
+	if q.table == nil && row[0] != "" && !strings.HasPrefix(row[0], "#") {
+		q.headerOnly = true
+	}
+	if q.headerOnly {
+		switch {
+		case parsingState == parsingStateError:
+			q.err = queryError(row)
+			return false
+		case q.isErrorHeader(row):
+			parsingState = parsingStateError
+			goto readRow
+		case q.table == nil || row[0] == q.table.Column(0).Name():
+			// without datatype annotation all values are treated as strings
+			q.table = newFluxTableMetadata(q.tablePosition)
+			q.tablePosition++
+			q.tableChanged = true
+			for i, n := range row {
+				column := newFluxColumn(i, stringDatatype)
+				column.SetName(n)
+				q.table.AddColumn(column)
 			}
-			q.err = fmt.Errorf("%s%s", message, reference)
+			goto readRow
+		}
+		if err := q.parseRecord(row); err != nil {
+			q.err = &QueryParseError{Row: q.rowNumber, Raw: row, Err: err}
 			return false
-		} else if parsingState == parsingStateNameRow {
-			if row[1] == "error" {
-				parsingState = parsingStateError
-			} else {
-				for i, n := range row[1:] {
-					if q.table.Column(i) != nil {
-						q.table.Column(i).SetName(n)
+		}
+	} else {
+		switch row[0] {
+		case "":
+			if parsingState == parsingStateError {
+				q.err = queryError(row[1:])
+				return false
+			} else if parsingState == parsingStateNameRow {
+				if row[1] == "error" {
+					parsingState = parsingStateError
+				} else {
+					for i, n := range row[1:] {
+						if q.table.Column(i) != nil {
+							q.table.Column(i).SetName(n)
+						}
 					}
+					parsingState = parsingStateNormal
+				}
+				goto readRow
+			} else if q.isErrorHeader(row[1:]) {
+				// error table can follow data rows without annotations
+				parsingState = parsingStateError
+				goto readRow
+			}
+			if err := q.parseRecord(row[1:]); err != nil {
+				q.err = &QueryParseError{Row: q.rowNumber, Raw: row, Err: err}
+				return false
+			}
+		case "#datatype", "#group", "#default":
+			// the first annotation introduces new table, dialect can request any subset of annotations
+			if parsingState != parsingStateNameRow {
+				q.table = newFluxTableMetadata(q.tablePosition)
+				q.tablePosition++
+				q.tableChanged = true
+				for i := range row[1:] {
+					q.table.AddColumn(newFluxColumn(i, stringDatatype))
+				}
+				// there come column names after annotations
+				parsingState = parsingStateNameRow
+			}
+			for i, v := range row[1:] {
+				column := q.table.Column(i)
+				if column == nil {
+					continue
+				}
+				switch row[0] {
+				case "#datatype":
+					column.SetDataType(v)
+				case "#group":
+					column.SetGroup(v == "true")
+				case "#default":
+					column.SetDefaultValue(v)
 				}
-				parsingState = parsingStateNormal
 			}
 			goto readRow
 		}
-		if q.table == nil {
-			q.err = errors.New("parsing error, table definition not found")
-			return false
-		}
-		if len(row)-1 != len(q.table.Columns()) {
-			q.err = fmt.Errorf("parsing error, row has different number of columns than table: %d vs %d", len(row)-1, len(q.table.Columns()))
-			return false
+	}
+	// don't close query
+	closer = func() {}
+	return true
+}
+
+// parseRecord creates actual record from data row cells, which must match columns of the actual table
+func (q *QueryTableResult) parseRecord(cells []string) error {
+	if q.table == nil {
+		return errors.New("parsing error, table definition not found")
+	}
+	if len(cells) != len(q.table.Columns()) {
+		return fmt.Errorf("parsing error, row has different number of columns than table: %d vs %d", len(cells), len(q.table.Columns()))
+	}
+	values := make(map[string]interface{})
+	for i, v := range cells {
+		column := q.table.Column(i)
+		value, err := toValue(stringTernary(v, column.DefaultValue()), column.DataType())
+		if err != nil {
+			return err
 		}
-		values := make(map[string]interface{})
-		for i, v := range row[1:] {
-			if q.table.Column(i) != nil {
-				values[q.table.Column(i).Name()], q.err = toValue(stringTernary(v, q.table.Column(i).DefaultValue()), q.table.Column(i).DataType())
-				if q.err != nil {
-					return false
+		values[column.Name()] = value
+	}
+	q.record = newFluxRecord(q.table.Position(), values)
+	return nil
+}
+
+// isErrorHeader returns true if cells are the header of the error table, which can come also after data rows.
+// Column named error in a data table is distinguished by the number of cells
+func (q *QueryTableResult) isErrorHeader(cells []string) bool {
+	return len(cells) > 0 && cells[0] == "error" && (q.table == nil || len(cells) != len(q.table.Columns()))
+}
+
+// QueryParseError is returned by QueryTableResult.Err when the query response cannot be parsed,
+// errors of the communication with the server are reported as *Error
+type QueryParseError struct {
+	// Row is 1-based number of the CSV row in the response, empty lines are not counted
+	Row int
+	// Raw holds cells of the row, nil if the row is not valid CSV
+	Raw []string
+	// Err is the parsing error
+	Err error
+}
+
+// Error fulfils error interface
+func (e *QueryParseError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err.Error())
+}
+
+// Unwrap returns the parsing error
+func (e *QueryParseError) Unwrap() error {
+	return e.Err
+}
+
+// FluxQueryError is returned by QueryTableResult.Err when the server reports failure of the query execution in the error table
+type FluxQueryError struct {
+	// Message describes the failure
+	Message string
+	// Reference is the error code of the server, empty if not set
+	Reference string
+}
+
+// Error fulfils error interface
+func (e *FluxQueryError) Error() string {
+	if e.Reference != "" {
+		return fmt.Sprintf("%s,%s", e.Message, e.Reference)
+	}
+	return e.Message
+}
+
+// queryError creates error from cells of the error table data row, containing message and optionally reference
+func queryError(cells []string) error {
+	err := &FluxQueryError{Message: "unknown query error"}
+	if len(cells) > 0 {
+		err.Message = cells[0]
+	}
+	if len(cells) > 1 {
+		err.Reference = cells[1]
+	}
+	return err
+}
+
+// SeriesPoint is a single time and value pair of a series
+type SeriesPoint struct {
+	Time  time.Time
+	Value interface{}
+}
+
+// ToSeries reads all remaining records of the result and groups their time and value into series, e.g. for charting.
+// Series are named by the group key columns, except the _start and _stop time bounds, in form col1=val1,col2=val2.
+// Returns error if parsing of the result fails or a record has no time
+func (q *QueryTableResult) ToSeries() (map[string][]SeriesPoint, error) {
+	series := make(map[string][]SeriesPoint)
+	var groupColumns []string
+	for q.Next() {
+		if q.TableChanged() {
+			groupColumns = groupColumns[:0]
+			for _, c := range q.TableMetadata().Columns() {
+				if c.IsGroup() && c.Name() != "_start" && c.Name() != "_stop" {
+					groupColumns = append(groupColumns, c.Name())
 				}
 			}
 		}
-		q.record = newFluxRecord(q.table.Position(), values)
-	case "#datatype":
-		q.table = newFluxTableMetadata(q.tablePosition)
-		q.tablePosition++
-		q.tableChanged = true
-		for i, d := range row[1:] {
-			q.table.AddColumn(newFluxColumn(i, d))
+		record := q.Record()
+		t, ok := record.ValueByKey("_time").(time.Time)
+		if !ok {
+			// result is not read till the end, so it must be closed here
+			q.Close()
+			return nil, fmt.Errorf("record of table %d has no time", record.Table())
 		}
-		goto readRow
-	case "#group":
-		for i, g := range row[1:] {
-			if q.table.Column(i) != nil {
-				q.table.Column(i).SetGroup(g == "true")
+		keys := make([]string, len(groupColumns))
+		for i, c := range groupColumns {
+			keys[i] = fmt.Sprintf("%s=%v", c, record.ValueByKey(c))
+		}
+		name := strings.Join(keys, ",")
+		series[name] = append(series[name], SeriesPoint{Time: t, Value: record.Value()})
+	}
+	if q.Err() != nil {
+		return nil, q.Err()
+	}
+	return series, nil
+}
+
+// RenderTable reads the whole result and writes it to w as ASCII tables with aligned columns,
+// one table per flux table, with column names taken from the table metadata
+func (q *QueryTableResult) RenderTable(w io.Writer) error {
+	var rows [][]string
+	for q.Next() {
+		if q.TableChanged() {
+			if err := renderRows(w, rows); err != nil {
+				// result is not read till the end, so it must be closed here
+				q.Close()
+				return err
+			}
+			columns := q.TableMetadata().Columns()
+			header := make([]string, len(columns))
+			for i, c := range columns {
+				header[i] = c.Name()
 			}
+			rows = [][]string{header}
 		}
-		goto readRow
-	case "#default":
-		for i, c := range row[1:] {
-			if q.table.Column(i) != nil {
-				q.table.Column(i).SetDefaultValue(c)
+		row := make([]string, len(rows[0]))
+		for i, c := range rows[0] {
+			row[i] = renderValue(q.Record().ValueByKey(c))
+		}
+		rows = append(rows, row)
+	}
+	if q.Err() != nil {
+		return q.Err()
+	}
+	return renderRows(w, rows)
+}
+
+// renderRows writes header, separator line and data rows aligned to the widest cell of each column
+func renderRows(w io.Writer, rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
 			}
 		}
-		// there comes column names after defaults
-		parsingState = parsingStateNameRow
-		goto readRow
 	}
-	// don't close query
-	closer = func() {}
-	return true
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	lines := append([][]string{rows[0], separator}, rows[1:]...)
+	var sb strings.Builder
+	for _, line := range lines {
+		for i, cell := range line {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			sb.WriteString(cell)
+			if i < len(line)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-len(cell)))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// renderValue formats record value for RenderTable
+func renderValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return fmt.Sprintf("%v", v)
 }
 
-// Err returns an error raised during flux query response parsing
+// Err returns an error raised during flux query response parsing.
+// Failure of the query reported by the server is *FluxQueryError, invalid response is *QueryParseError
+// and failure of reading the response is *Error
 func (q *QueryTableResult) Err() error {
 	return q.err
 }
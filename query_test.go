@@ -5,14 +5,22 @@
 package influxdb2
 
 import (
+	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/bonitoo-io/influxdb-client-go/domain"
 	"github.com/bonitoo-io/influxdb-client-go/internal/gzip"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -103,8 +111,8 @@ func TestQueryCVSResultSingleTable(t *testing.T) {
 	require.Nil(t, queryResult.Err())
 }
 
-func TestQueryCVSResultMultiTables(t *testing.T) {
-	csvTable := `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string,string
+// multiTablesCSV is query response with four tables of different value types
+const multiTablesCSV = `#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string,string,string
 #group,false,false,true,true,false,false,true,true,true,true
 #default,_result,,,,,,,,,
 ,result,table,_start,_stop,_time,_value,_field,_measurement,a,b
@@ -133,6 +141,9 @@ func TestQueryCVSResultMultiTables(t *testing.T) {
 ,,3,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T22:08:44.969100374Z,2,i,test,0,adsfasdf
 
 `
+
+func TestQueryCVSResultMultiTables(t *testing.T) {
+	csvTable := multiTablesCSV
 	expectedTable1 := &FluxTableMetadata{position: 0,
 		columns: []*FluxColumn{
 			{dataType: "string", defaultValue: "_result", name: "result", group: false, index: 0},
@@ -464,7 +475,1030 @@ func TestErrorInRow(t *testing.T) {
 
 }
 
+func TestLateErrorTable(t *testing.T) {
+	rows := []string{
+		`#datatype,string,long,dateTime:RFC3339,double`,
+		`#group,false,false,false,false`,
+		`#default,_result,,,`,
+		`,result,table,_time,_value`,
+		`,,0,2020-02-18T10:34:08.135814545Z,1.4`,
+		`,,0,2020-02-18T22:08:44.850214724Z,6.6`,
+		``,
+	}
+	tests := []struct {
+		name      string
+		rows      []string
+		errorRows []string
+	}{
+		{
+			name: "annotated error table",
+			rows: rows,
+			errorRows: []string{
+				`#datatype,string,string`,
+				`#group,true,true`,
+				`#default,,`,
+				`,error,reference`,
+				`,query terminated: memory allocation limit reached,897`,
+			},
+		},
+		{
+			name: "error table without annotations",
+			rows: rows,
+			errorRows: []string{
+				`,error,reference`,
+				`,query terminated: memory allocation limit reached,897`,
+			},
+		},
+		{
+			name: "header only dialect",
+			rows: []string{
+				`result,table,_time,_value`,
+				`_result,0,2020-02-18T10:34:08.135814545Z,1.4`,
+				`_result,0,2020-02-18T22:08:44.850214724Z,6.6`,
+				``,
+			},
+			errorRows: []string{
+				`error,reference`,
+				`query terminated: memory allocation limit reached,897`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := strings.NewReader(makeCSVstring(append(test.rows, test.errorRows...)))
+			csvReader := csv.NewReader(reader)
+			csvReader.FieldsPerRecord = -1
+			queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+			count := 0
+			for queryResult.Next() {
+				count++
+			}
+			assert.Equal(t, 2, count)
+			require.NotNil(t, queryResult.Err())
+			assert.Equal(t, "query terminated: memory allocation limit reached,897", queryResult.Err().Error())
+		})
+	}
+}
+
+// failingReader fails each read with err
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// closeRecorder records whether it was closed
+type closeRecorder struct {
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestQueryErrorTypes(t *testing.T) {
+	newResult := func(rows ...string) *QueryTableResult {
+		reader := strings.NewReader(makeCSVstring(rows))
+		csvReader := csv.NewReader(reader)
+		csvReader.FieldsPerRecord = -1
+		return &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+	}
+	annotations := []string{
+		`#datatype,string,long,double`,
+		`#group,false,false,false`,
+		`#default,_result,,`,
+		`,result,table,_value`,
+	}
+
+	queryResult := newResult(append(annotations, `,,0,1.4`, `,,0,x`)...)
+	require.True(t, queryResult.Next(), queryResult.Err())
+	require.False(t, queryResult.Next())
+	require.NotNil(t, queryResult.Err())
+	var parseErr *QueryParseError
+	require.True(t, errors.As(queryResult.Err(), &parseErr))
+	assert.Equal(t, 6, parseErr.Row)
+	assert.Equal(t, []string{"", "", "0", "x"}, parseErr.Raw)
+	assert.Equal(t, `row 6: strconv.ParseFloat: parsing "x": invalid syntax`, parseErr.Error())
+	var fluxErr *FluxQueryError
+	assert.False(t, errors.As(queryResult.Err(), &fluxErr))
+
+	queryResult = newResult(append(annotations, `,,0,1.4,extra`)...)
+	require.False(t, queryResult.Next())
+	require.True(t, errors.As(queryResult.Err(), &parseErr))
+	assert.Equal(t, 5, parseErr.Row)
+	assert.Equal(t, "row 5: parsing error, row has different number of columns than table: 4 vs 3", parseErr.Error())
+
+	queryResult = newResult(append(annotations, `,,0,"1.4`)...)
+	require.False(t, queryResult.Next())
+	require.True(t, errors.As(queryResult.Err(), &parseErr))
+	assert.Equal(t, 5, parseErr.Row)
+	var csvErr *csv.ParseError
+	assert.True(t, errors.As(queryResult.Err(), &csvErr))
+
+	queryResult = newResult(
+		`#datatype,string,string`,
+		`#group,true,true`,
+		`#default,,`,
+		`,error,reference`,
+		`,failed to create physical plan,897`,
+	)
+	require.False(t, queryResult.Next())
+	require.True(t, errors.As(queryResult.Err(), &fluxErr))
+	assert.Equal(t, "failed to create physical plan", fluxErr.Message)
+	assert.Equal(t, "897", fluxErr.Reference)
+	assert.False(t, errors.As(queryResult.Err(), &parseErr))
+	var perror *Error
+	assert.False(t, errors.As(queryResult.Err(), &perror))
+
+	queryResult = newResult(`error,reference`, `syntax error,`)
+	require.False(t, queryResult.Next())
+	require.True(t, errors.As(queryResult.Err(), &fluxErr))
+	assert.Equal(t, "", fluxErr.Reference)
+	assert.Equal(t, "syntax error", fluxErr.Error())
+
+	queryResult = &QueryTableResult{Closer: ioutil.NopCloser(nil), csvReader: csv.NewReader(failingReader{errors.New("connection reset")})}
+	require.False(t, queryResult.Next())
+	require.True(t, errors.As(queryResult.Err(), &perror))
+	assert.Equal(t, "connection reset", perror.Error())
+}
+
 func makeCSVstring(rows []string) string {
 	csvTable := strings.Join(rows, "\r\n")
 	return fmt.Sprintf("%s\r\n", csvTable)
 }
+
+func TestQueryWithTimeout(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,dateTime:RFC3339,double`,
+		`#group,false,false,false,false`,
+		`#default,_result,,,`,
+		`,result,table,_time,_value`,
+		`,,0,2020-02-18T10:34:08.135814545Z,1.4`,
+	})
+	var timeoutHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeoutHeader = r.Header.Get("X-Influx-Query-Timeout")
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	result, err := queryApi.QueryWithTimeout(context.Background(), "flux", 90*time.Second)
+	require.Nil(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "1m30s", timeoutHeader)
+	require.True(t, result.Next())
+	assert.Equal(t, 1.4, result.Record().Value())
+	require.False(t, result.Next())
+	require.Nil(t, result.Err())
+
+	result, err = queryApi.QueryWithTimeout(context.Background(), "flux", 0)
+	require.Nil(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "", timeoutHeader)
+	require.NoError(t, result.Close())
+}
+
+func TestQueryCVSResultHeaderOnlyDialect(t *testing.T) {
+	csvRows := []string{
+		`result,table,_time,_value,_field`,
+		`_result,0,2020-02-18T10:34:08.135814545Z,1.4,f`,
+		`_result,0,2020-02-18T22:08:44.850214724Z,6.6,f`,
+		``,
+		`result,table,_time,_value,_field,a`,
+		`_result,1,2020-02-18T10:34:08.135814545Z,4,i,x`,
+	}
+	reader := strings.NewReader(makeCSVstring(csvRows))
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	require.True(t, queryResult.Next(), queryResult.Err())
+	require.Nil(t, queryResult.Err())
+	assert.True(t, queryResult.TableChanged())
+	assert.Equal(t, 0, queryResult.TablePosition())
+	require.Len(t, queryResult.TableMetadata().Columns(), 5)
+	assert.Equal(t, "_value", queryResult.TableMetadata().Column(3).Name())
+	assert.Equal(t, "string", queryResult.TableMetadata().Column(3).DataType())
+	assert.Equal(t, map[string]interface{}{
+		"result": "_result",
+		"table":  "0",
+		"_time":  "2020-02-18T10:34:08.135814545Z",
+		"_value": "1.4",
+		"_field": "f",
+	}, queryResult.Record().Values())
+
+	require.True(t, queryResult.Next(), queryResult.Err())
+	assert.False(t, queryResult.TableChanged())
+	assert.Equal(t, "6.6", queryResult.Record().Value())
+
+	require.True(t, queryResult.Next(), queryResult.Err())
+	assert.True(t, queryResult.TableChanged())
+	assert.Equal(t, 1, queryResult.TablePosition())
+	require.Len(t, queryResult.TableMetadata().Columns(), 6)
+	assert.Equal(t, "x", queryResult.Record().ValueByKey("a"))
+
+	require.False(t, queryResult.Next())
+	require.Nil(t, queryResult.Err())
+
+	csvRowsError := []string{
+		`error,reference`,
+		`failed to create physical plan,897`,
+	}
+	reader = strings.NewReader(makeCSVstring(csvRowsError))
+	csvReader = csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult = &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	require.False(t, queryResult.Next())
+	require.NotNil(t, queryResult.Err())
+	assert.Equal(t, "failed to create physical plan,897", queryResult.Err().Error())
+}
+
+func TestQueryRawWithHeaders(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,dateTime:RFC3339,double`,
+		`#group,false,false,false,false`,
+		`#default,_result,,,`,
+		`,result,table,_time,_value`,
+		`,,0,2020-02-18T10:34:08.135814545Z,1.4`,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("X-RateLimit-Remaining", "99")
+		w.Header().Set("Trace-Id", "3a5b7c")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	result, headers, err := queryApi.QueryRawWithHeaders(context.Background(), "flux", nil)
+	require.Nil(t, err)
+	assert.Equal(t, csvTable, result)
+	require.NotNil(t, headers)
+	assert.Equal(t, "99", headers.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "3a5b7c", headers.Get("Trace-Id"))
+}
+
+func TestQueryRawBytes(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,base64Binary`,
+		`#group,false,false,false`,
+		`#default,_result,,`,
+		`,result,table,_value`,
+		`,,0,AAEC/w==`,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+
+	result, err := client.QueryApi("org").QueryRawBytes(context.Background(), "flux", nil)
+	require.Nil(t, err)
+	assert.Equal(t, []byte(csvTable), result)
+}
+
+func TestQueryBase64Binary(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,base64Binary,string`,
+		`#group,false,false,false,false`,
+		`#default,_result,,,`,
+		`,result,table,_value,s`,
+		`,,0,AAEC/w==,AAEC/w==`,
+		`,,0,,`,
+	})
+	reader := strings.NewReader(csvTable)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	require.True(t, queryResult.Next(), queryResult.Err())
+	assert.Equal(t, []byte{0, 1, 2, 255}, queryResult.Record().Value())
+	v, ok := queryResult.Record().BytesByKey("_value")
+	require.True(t, ok)
+	assert.Equal(t, []byte{0, 1, 2, 255}, v)
+	_, ok = queryResult.Record().StringByKey("_value")
+	assert.False(t, ok)
+	_, ok = queryResult.Record().BytesByKey("s")
+	assert.False(t, ok)
+
+	require.True(t, queryResult.Next(), queryResult.Err())
+	v, ok = queryResult.Record().BytesByKey("_value")
+	assert.True(t, ok)
+	assert.Len(t, v, 0)
+	require.False(t, queryResult.Next())
+	require.Nil(t, queryResult.Err())
+}
+
+func TestQueryRawDateTimeFormat(t *testing.T) {
+	var query domain.Query
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = domain.Query{}
+		err := json.NewDecoder(r.Body).Decode(&query)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	_, err := queryApi.QueryRaw(context.Background(), "flux", DialectWithDateTimeFormat(DateTimeFormatRFC3339Nano))
+	require.Nil(t, err)
+	require.NotNil(t, query.Dialect)
+	require.NotNil(t, query.Dialect.DateTimeFormat)
+	assert.Equal(t, "RFC3339Nano", *query.Dialect.DateTimeFormat)
+	assert.Equal(t, *DefaultDialect().Annotations, *query.Dialect.Annotations)
+
+	_, err = queryApi.QueryRaw(context.Background(), "flux", DefaultDialect())
+	require.Nil(t, err)
+	require.NotNil(t, query.Dialect)
+	assert.Nil(t, query.Dialect.DateTimeFormat)
+}
+
+func TestQueryResultToSeries(t *testing.T) {
+	reader := strings.NewReader(multiTablesCSV)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	series, err := queryResult.ToSeries()
+	require.Nil(t, err)
+	require.Len(t, series, 4)
+	f1 := series["_field=f,_measurement=test,a=1,b=adsfasdf"]
+	require.Len(t, f1, 2)
+	assert.Equal(t, SeriesPoint{Time: mustParseTime("2020-02-18T10:34:08.135814545Z"), Value: 1.4}, f1[0])
+	assert.Equal(t, SeriesPoint{Time: mustParseTime("2020-02-18T22:08:44.850214724Z"), Value: 6.6}, f1[1])
+	require.Len(t, series["_field=i,_measurement=test,a=1,b=adsfasdf"], 2)
+	assert.Equal(t, int64(-1), series["_field=i,_measurement=test,a=1,b=adsfasdf"][1].Value)
+	require.Len(t, series["_field=f,_measurement=test,a=0,b=adsfasdf"], 2)
+	assert.Equal(t, true, series["_field=f,_measurement=test,a=0,b=adsfasdf"][1].Value)
+	require.Len(t, series["_field=i,_measurement=test,a=0,b=adsfasdf"], 2)
+	assert.Equal(t, uint64(2), series["_field=i,_measurement=test,a=0,b=adsfasdf"][1].Value)
+
+	// result is closed also when a record has no time
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,double`,
+		`#group,false,false,false`,
+		`#default,_result,,`,
+		`,result,table,_value`,
+		`,,0,1.5`,
+		`,,0,2.5`,
+	})
+	closer := &closeRecorder{}
+	csvReader = csv.NewReader(strings.NewReader(csvTable))
+	csvReader.FieldsPerRecord = -1
+	queryResult = &QueryTableResult{Closer: closer, csvReader: csvReader}
+	_, err = queryResult.ToSeries()
+	require.NotNil(t, err)
+	assert.Equal(t, "record of table 0 has no time", err.Error())
+	assert.True(t, closer.closed)
+}
+
+func TestQueryFirst(t *testing.T) {
+	csvTable := multiTablesCSV
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	var row struct {
+		Time  time.Time
+		Value float64
+		Field string
+		A     string
+	}
+	err := queryApi.QueryFirst(context.Background(), "flux", &row)
+	require.Nil(t, err)
+	assert.Equal(t, mustParseTime("2020-02-18T10:34:08.135814545Z"), row.Time)
+	assert.Equal(t, 1.4, row.Value)
+	assert.Equal(t, "f", row.Field)
+	assert.Equal(t, "1", row.A)
+
+	csvTable = ""
+	err = queryApi.QueryFirst(context.Background(), "flux", &row)
+	require.NotNil(t, err)
+	assert.Equal(t, "query result has no records", err.Error())
+}
+
+func TestQueryAll(t *testing.T) {
+	csvTable := multiTablesCSV
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	type row struct {
+		Time  time.Time
+		Value interface{}
+		Field string `flux:"_field"`
+		A     string
+	}
+	var rows []row
+	err := queryApi.QueryAll(context.Background(), "flux", &rows)
+	require.Nil(t, err)
+	require.Len(t, rows, 8)
+	assert.Equal(t, row{Time: mustParseTime("2020-02-18T10:34:08.135814545Z"), Value: 1.4, Field: "f", A: "1"}, rows[0])
+	assert.Equal(t, int64(-1), rows[3].Value)
+	assert.Equal(t, true, rows[5].Value)
+	assert.Equal(t, uint64(2), rows[7].Value)
+
+	var ptrRows []*row
+	err = queryApi.QueryAll(context.Background(), "flux", &ptrRows)
+	require.Nil(t, err)
+	require.Len(t, ptrRows, 8)
+	assert.Equal(t, "i", ptrRows[2].Field)
+
+	var typed []struct {
+		Value float64
+	}
+	err = queryApi.QueryAll(context.Background(), "flux", &typed)
+	require.NotNil(t, err)
+	assert.Len(t, typed, 0)
+
+	csvTable = makeCSVstring([]string{
+		`#datatype,string,long,double,string`,
+		`#group,false,false,false,true`,
+		`#default,_result,,,`,
+		`,result,table,_value,_field`,
+		`,,0,1.5,f`,
+		``,
+		`#datatype,string,string`,
+		`#group,true,true`,
+		`#default,,`,
+		`,error,reference`,
+		`,query terminated,897`,
+	})
+	rows = nil
+	err = queryApi.QueryAll(context.Background(), "flux", &rows)
+	require.NotNil(t, err)
+	assert.Equal(t, "query terminated,897", err.Error())
+	assert.Len(t, rows, 0)
+
+	err = queryApi.QueryAll(context.Background(), "flux", rows)
+	require.NotNil(t, err)
+	assert.Equal(t, "destination must be a non-nil pointer to a slice", err.Error())
+	var ints []int
+	err = queryApi.QueryAll(context.Background(), "flux", &ints)
+	require.NotNil(t, err)
+	assert.Equal(t, "destination slice elements must be structs or pointers to structs", err.Error())
+}
+
+func TestQueryTagValues(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,string`,
+		`#group,false,false,false`,
+		`#default,_result,0,`,
+		`,result,table,_value`,
+		`,,,server01`,
+		`,,,server02`,
+	})
+	var query domain.Query
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = domain.Query{}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	queryApi := NewClient(server.URL, "a").QueryApi("org")
+
+	values, err := queryApi.TagValues(context.Background(), "my-bucket", "cpu", "host")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"server01", "server02"}, values)
+	assert.Equal(t, "import \"influxdata/influxdb/schema\"\n"+
+		`schema.tagValues(bucket: "my-bucket", tag: "host", predicate: (r) => r._measurement == "cpu")`, query.Query)
+
+	_, err = queryApi.TagValues(context.Background(), `my"bucket`, `c\pu${x}`, `ho"st`)
+	require.Nil(t, err)
+	assert.Equal(t, "import \"influxdata/influxdb/schema\"\n"+
+		`schema.tagValues(bucket: "my\"bucket", tag: "ho\"st", predicate: (r) => r._measurement == "c\\pu\${x}")`, query.Query)
+
+	csvTable = ""
+	values, err = queryApi.TagValues(context.Background(), "my-bucket", "", "host")
+	require.Nil(t, err)
+	assert.Len(t, values, 0)
+	assert.Equal(t, "import \"influxdata/influxdb/schema\"\n"+
+		`schema.tagValues(bucket: "my-bucket", tag: "host")`, query.Query)
+}
+
+func TestQueryRetry(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,dateTime:RFC3339,double`,
+		`#group,false,false,false,false`,
+		`#default,_result,,,`,
+		`,result,table,_time,_value`,
+		`,,0,2020-02-18T10:34:08.135814545Z,1.4`,
+	})
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+
+	// retrying is disabled by default
+	client := NewClientWithOptions(server.URL, "a", DefaultOptions().SetRetryInterval(10))
+	_, err := client.QueryApi("org").QueryRaw(context.Background(), "flux", nil)
+	require.NotNil(t, err)
+	assert.Equal(t, 1, requests)
+
+	requests = 0
+	client = NewClientWithOptions(server.URL, "a", DefaultOptions().SetRetryInterval(10).SetQueryMaxRetries(2))
+	result, err := client.QueryApi("org").Query(context.Background(), "flux")
+	require.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	require.True(t, result.Next())
+	assert.Equal(t, 1.4, result.Record().Value())
+
+	requests = 0
+	raw, err := client.QueryApi("org").QueryRaw(context.Background(), "flux", nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, csvTable, raw)
+}
+
+func TestQueryGzipBody(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,double`,
+		`#group,false,false,false`,
+		`#default,_result,,`,
+		`,result,table,_value`,
+		`,,0,1.4`,
+	})
+	var encoding string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(csvTable))
+	}))
+	defer server.Close()
+	logger := &testLogger{}
+	client := NewClientWithOptions(server.URL, "a", DefaultOptions().SetUseGZipQuery(true).SetLogger(logger))
+
+	result, err := client.QueryApi("org").Query(context.Background(), "flux")
+	require.Nil(t, err)
+	require.True(t, result.Next())
+	assert.Equal(t, "gzip", encoding)
+	// gzip magic header
+	require.True(t, len(body) > 2)
+	assert.Equal(t, []byte{0x1f, 0x8b}, body[:2])
+
+	// failing compression falls back to uncompressed body
+	origCompress := compressQuery
+	defer func() { compressQuery = origCompress }()
+	compressQuery = func([]byte) ([]byte, error) {
+		return nil, fmt.Errorf("compression failed")
+	}
+	result, err = client.QueryApi("org").Query(context.Background(), "flux")
+	require.Nil(t, err)
+	require.True(t, result.Next())
+	assert.Equal(t, 1.4, result.Record().Value())
+	assert.Equal(t, "", encoding)
+	var q domain.Query
+	require.Nil(t, json.Unmarshal(body, &q))
+	assert.Equal(t, "flux", q.Query)
+	assert.Equal(t, []string{"W Query body compression error: compression failed, sending uncompressed\n"}, logger.messages)
+}
+
+func TestQueryParams(t *testing.T) {
+	var params url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := NewClientWithOptions(server.URL, "a", DefaultOptions().SetQueryParams(map[string]string{
+		"feature": "on",
+		"org":     "other",
+	}))
+
+	_, err := client.QueryApi("my-org").QueryRaw(context.Background(), "flux", nil)
+	require.Nil(t, err)
+	assert.Equal(t, url.Values{"feature": []string{"on"}, "org": []string{"my-org"}}, params)
+}
+
+func TestQueryWithParams(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	result, err := queryApi.QueryWithParams(context.Background(), `from(bucket: "b") |> filter(fn: (r) => contains(value: r.host, set: v.hosts))`,
+		map[string]interface{}{"hosts": []string{"a", "b"}})
+	require.Nil(t, err)
+	require.NoError(t, result.Close())
+	assert.JSONEq(t, `{"type":"File","body":[{"type":"OptionStatement","assignment":{"type":"VariableAssignment",
+		"id":{"type":"Identifier","name":"v"},
+		"init":{"type":"ObjectExpression","properties":[{"type":"Property","key":{"type":"Identifier","name":"hosts"},
+			"value":{"type":"ArrayExpression","elements":[{"type":"StringLiteral","value":"a"},{"type":"StringLiteral","value":"b"}]}}]}}}]}`,
+		string(body["extern"]))
+
+	result, err = queryApi.QueryWithParams(context.Background(), "flux",
+		map[string]interface{}{"limits": map[string]interface{}{"max": 1.5, "count": 3}})
+	require.Nil(t, err)
+	require.NoError(t, result.Close())
+	assert.JSONEq(t, `{"type":"File","body":[{"type":"OptionStatement","assignment":{"type":"VariableAssignment",
+		"id":{"type":"Identifier","name":"v"},
+		"init":{"type":"ObjectExpression","properties":[{"type":"Property","key":{"type":"Identifier","name":"limits"},
+			"value":{"type":"ObjectExpression","properties":[
+				{"type":"Property","key":{"type":"Identifier","name":"count"},"value":{"type":"IntegerLiteral","value":"3"}},
+				{"type":"Property","key":{"type":"Identifier","name":"max"},"value":{"type":"FloatLiteral","value":1.5}}]}}]}}}]}`,
+		string(body["extern"]))
+
+	_, err = queryApi.QueryWithParams(context.Background(), "flux", map[string]interface{}{"ch": make(chan int)})
+	require.NotNil(t, err)
+	assert.Equal(t, "parameter ch: unsupported parameter type chan int", err.Error())
+}
+
+func TestQueryResultRenderTable(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,dateTime:RFC3339,double,string`,
+		`#group,false,false,false,false,true`,
+		`#default,_result,,,,`,
+		`,result,table,_time,_value,_field`,
+		`,,0,2020-02-18T10:34:08.135814545Z,1.4,f`,
+		`,,0,2020-02-18T22:08:44.850214724Z,16.6,f`,
+	})
+	reader := strings.NewReader(csvTable)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	var sb strings.Builder
+	require.Nil(t, queryResult.RenderTable(&sb))
+	expected := `result   table  _time                           _value  _field
+-------  -----  ------------------------------  ------  ------
+_result  0      2020-02-18T10:34:08.135814545Z  1.4     f
+_result  0      2020-02-18T22:08:44.850214724Z  16.6    f
+
+`
+	assert.Equal(t, expected, sb.String())
+
+	// result is closed when writing fails in the middle of the result
+	closer := &closeRecorder{}
+	csvReader = csv.NewReader(strings.NewReader(multiTablesCSV))
+	csvReader.FieldsPerRecord = -1
+	queryResult = &QueryTableResult{Closer: closer, csvReader: csvReader}
+	err := queryResult.RenderTable(failingWriter{err: errors.New("disk full")})
+	require.NotNil(t, err)
+	assert.Equal(t, "disk full", err.Error())
+	assert.True(t, closer.closed)
+}
+
+// failingWriter fails each write with err
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+func TestExportPerTable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(multiTablesCSV))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "export")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	client := NewClient(server.URL, "a")
+
+	files, err := client.QueryApi("org").ExportPerTable(context.Background(), "flux", dir)
+	require.Nil(t, err)
+	require.Len(t, files, 4)
+	for i, f := range files {
+		assert.Equal(t, filepath.Join(dir, fmt.Sprintf("table_%d.csv", i)), f)
+		content, err := ioutil.ReadFile(f)
+		require.Nil(t, err)
+		rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+		require.Nil(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, []string{"result", "table", "_start", "_stop", "_time", "_value", "_field", "_measurement", "a", "b"}, rows[0])
+		assert.Equal(t, strconv.Itoa(i), rows[1][1])
+	}
+	content, err := ioutil.ReadFile(files[0])
+	require.Nil(t, err)
+	assert.Contains(t, string(content), "_result,0,2020-02-17T22:19:49.747562847Z,2020-02-18T22:19:49.747562847Z,2020-02-18T10:34:08.135814545Z,1.4,f,test,1,adsfasdf\n")
+
+	_, err = client.QueryApi("org").ExportPerTable(context.Background(), "flux", filepath.Join(dir, "missing"))
+	require.NotNil(t, err)
+}
+
+func TestRecordValuesInColumnOrder(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#datatype,string,long,string,double,dateTime:RFC3339,string,string`,
+		`#group,false,false,true,false,false,true,true`,
+		`#default,_result,,,,,,`,
+		`,result,table,_measurement,_value,_time,_field,zone`,
+		`,,0,test,1.4,2020-02-18T10:34:08.135814545Z,f,eu`,
+	})
+	reader := strings.NewReader(csvTable)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+	require.True(t, queryResult.Next(), queryResult.Err())
+
+	values := queryResult.Record().ValuesInColumnOrder(queryResult.TableMetadata())
+	assert.Equal(t, []interface{}{"_result", int64(0), "test", 1.4, mustParseTime("2020-02-18T10:34:08.135814545Z"), "f", "eu"}, values)
+
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = renderValue(v)
+	}
+	assert.Equal(t, "_result,0,test,1.4,2020-02-18T10:34:08.135814545Z,f,eu", strings.Join(cells, ","))
+}
+
+func TestQueryResultDecode(t *testing.T) {
+	reader := strings.NewReader(multiTablesCSV)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+
+	type row struct {
+		Time        time.Time `influxdb:"_time"`
+		Value       float64   `influxdb:"_value"`
+		Measurement string    `influxdb:"_measurement"`
+		Table       int64     `influxdb:"table"`
+		Field       string    `influxdb:"-"`
+		Other       string
+	}
+	var r row
+	require.NotNil(t, queryResult.Decode(&r))
+	require.True(t, queryResult.Next(), queryResult.Err())
+	require.Nil(t, queryResult.Decode(&r))
+	assert.Equal(t, row{
+		Time:        mustParseTime("2020-02-18T10:34:08.135814545Z"),
+		Value:       1.4,
+		Measurement: "test",
+	}, r)
+	assert.NotNil(t, queryResult.Decode(r))
+
+	var missing struct {
+		Host string `influxdb:"host"`
+	}
+	err := queryResult.Decode(&missing)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode field Host, column host not found in table 0", err.Error())
+
+	// skip to the bool table
+	for i := 0; i < 4; i++ {
+		require.True(t, queryResult.Next(), queryResult.Err())
+	}
+	err = queryResult.Decode(&r)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode value false of type bool into field Value of type float64", err.Error())
+
+	var b struct {
+		Value bool   `influxdb:"_value"`
+		Tag   string `influxdb:"a"`
+	}
+	require.Nil(t, queryResult.Decode(&b))
+	assert.False(t, b.Value)
+	assert.Equal(t, "0", b.Tag)
+}
+
+func TestQueryTemplate(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "a")
+	queryApi := client.QueryApi("org")
+
+	result, err := queryApi.QueryTemplate(context.Background(), `from(bucket: {{bucket}}) |> filter(fn: (r) => r._measurement == {{ measurement }})`,
+		map[string]string{"bucket": "my-bucket", "measurement": `cpu") |> drop(columns: ["host`})
+	require.Nil(t, err)
+	require.NoError(t, result.Close())
+	assert.Equal(t, `from(bucket: "my-bucket") |> filter(fn: (r) => r._measurement == "cpu\") |> drop(columns: [\"host")`, body["query"])
+
+	query, err := executeTemplate(`r.host == {{host}} or r.host == {{host}}`, map[string]string{"host": `a\" ${b}`})
+	require.Nil(t, err)
+	assert.Equal(t, `r.host == "a\\\" \${b}" or r.host == "a\\\" \${b}"`, query)
+
+	_, err = queryApi.QueryTemplate(context.Background(), `from(bucket: {{bucket}}) |> range(start: {{start}})`, map[string]string{"start": "-1h"})
+	require.NotNil(t, err)
+	assert.Equal(t, "query template placeholder(s) without value: bucket", err.Error())
+}
+
+func TestQueryValidate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/query/ast", r.URL.Path)
+		var body map[string]string
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		switch body["query"] {
+		case `from(bucket: "b") |> range(start: -1h)`:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"ast":{"type":"Package","package":"main","files":[{"type":"File","body":[{"type":"ExpressionStatement"}]}]}}`))
+		case `from(bucket: "b") |> range(start: -1h`:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"invalid","message":"invalid AST: loc 1:38-1:38: expected RPAREN, got EOF"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"ast":{"type":"Package","files":[{"type":"File","body":[{"type":"BadStatement","errors":[{"msg":"invalid statement @1:1-1:2: )"}]}]}]}}`))
+		}
+	}))
+	defer server.Close()
+	queryApi := NewClient(server.URL, "a").QueryApi("org")
+
+	assert.Nil(t, queryApi.Validate(context.Background(), `from(bucket: "b") |> range(start: -1h)`))
+
+	err := queryApi.Validate(context.Background(), `from(bucket: "b") |> range(start: -1h`)
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid: invalid AST: loc 1:38-1:38: expected RPAREN, got EOF", err.Error())
+
+	err = queryApi.Validate(context.Background(), `)`)
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid flux query: invalid statement @1:1-1:2: )", err.Error())
+}
+
+func TestQueryWithDialect(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(makeCSVstring([]string{
+			`#datatype;string;long;dateTime:RFC3339Nano;double;string`,
+			`#group;false;false;false;false;true`,
+			`;result;table;_time;_value;_field`,
+			`;_result;0;2020-02-18T10:34:08.135814545Z;1.4;f`,
+			``,
+			`#datatype;string;long;dateTime:RFC3339Nano;long`,
+			`#group;false;false;false;false`,
+			`;result;table;_time;_value`,
+			`;_result;1;2020-02-18T10:34:09Z;2`,
+		})))
+	}))
+	defer server.Close()
+	queryApi := NewClient(server.URL, "a").QueryApi("org")
+
+	annotations := []string{"datatype", "group"}
+	delimiter := ";"
+	dialect := DialectWithDateTimeFormat(DateTimeFormatRFC3339Nano)
+	dialect.Annotations = &annotations
+	dialect.Delimiter = &delimiter
+	result, err := queryApi.QueryWithDialect(context.Background(), "flux", dialect)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"annotations":["datatype","group"],"dateTimeFormat":"RFC3339Nano","delimiter":";","header":true}`, string(body["dialect"]))
+
+	require.True(t, result.Next(), result.Err())
+	assert.True(t, result.TableChanged())
+	assert.Equal(t, "table 0: result(string),table(long),_time(dateTime:RFC3339Nano),_value(double),_field(string)", result.TableMetadata().String())
+	assert.True(t, result.TableMetadata().Column(4).IsGroup())
+	assert.Equal(t, mustParseTime("2020-02-18T10:34:08.135814545Z"), result.Record().Time())
+	assert.Equal(t, 1.4, result.Record().Value())
+	require.True(t, result.Next(), result.Err())
+	assert.True(t, result.TableChanged())
+	assert.Equal(t, 1, result.TablePosition())
+	assert.Equal(t, int64(2), result.Record().Value())
+	assert.False(t, result.Next())
+	require.Nil(t, result.Err())
+
+	header := false
+	dialect.Header = &header
+	_, err = queryApi.QueryWithDialect(context.Background(), "flux", dialect)
+	require.NotNil(t, err)
+	assert.Equal(t, "dialect without header is not supported, column names are required for parsing the result, use QueryRaw instead", err.Error())
+}
+
+func TestQueryCVSResultAnnotationsOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations []string
+		value       interface{}
+		field       string
+	}{
+		{
+			name: "datatype before group",
+			annotations: []string{
+				`#datatype,string,long,string,double`,
+				`#group,false,false,true,false`,
+			},
+			value: 1.4,
+		},
+		{
+			name: "group before datatype",
+			annotations: []string{
+				`#group,false,false,true,false`,
+				`#datatype,string,long,string,double`,
+			},
+			value: 1.4,
+		},
+		{
+			name: "default before group and datatype",
+			annotations: []string{
+				`#default,_result,,g,`,
+				`#group,false,false,true,false`,
+				`#datatype,string,long,string,double`,
+			},
+			value: 1.4,
+			field: "g",
+		},
+		{
+			name: "group only",
+			annotations: []string{
+				`#group,false,false,true,false`,
+			},
+			value: "1.4",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := append(test.annotations,
+				`,result,table,_field,_value`,
+				`,_result,0,f,1.4`,
+				`,_result,0,,1.4`,
+			)
+			reader := strings.NewReader(makeCSVstring(rows))
+			csvReader := csv.NewReader(reader)
+			csvReader.FieldsPerRecord = -1
+			queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+			require.True(t, queryResult.Next(), queryResult.Err())
+			require.Len(t, queryResult.TableMetadata().Columns(), 4)
+			assert.Equal(t, []string{"result", "table", "_field", "_value"}, []string{
+				queryResult.TableMetadata().Column(0).Name(),
+				queryResult.TableMetadata().Column(1).Name(),
+				queryResult.TableMetadata().Column(2).Name(),
+				queryResult.TableMetadata().Column(3).Name(),
+			})
+			assert.True(t, queryResult.TableMetadata().Column(2).IsGroup())
+			assert.False(t, queryResult.TableMetadata().Column(3).IsGroup())
+			assert.Equal(t, test.value, queryResult.Record().Value())
+			assert.Equal(t, "f", queryResult.Record().Field())
+			require.True(t, queryResult.Next(), queryResult.Err())
+			assert.Equal(t, test.field, queryResult.Record().ValueByKey("_field"))
+			assert.False(t, queryResult.Next())
+			require.Nil(t, queryResult.Err())
+		})
+	}
+}
+
+func TestQueryCVSResultWithoutDatatype(t *testing.T) {
+	csvTable := makeCSVstring([]string{
+		`#group,false,false,true,false`,
+		`#default,_result,,,`,
+		`,result,table,_field,_value`,
+		`,,0,f,1.4`,
+	})
+	reader := strings.NewReader(csvTable)
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	queryResult := &QueryTableResult{Closer: ioutil.NopCloser(reader), csvReader: csvReader}
+	require.True(t, queryResult.Next(), queryResult.Err())
+	assert.Equal(t, map[string]interface{}{"result": "_result", "table": "0", "_field": "f", "_value": "1.4"}, queryResult.Record().Values())
+	assert.True(t, queryResult.TableMetadata().Column(2).IsGroup())
+	assert.False(t, queryResult.Next())
+	require.Nil(t, queryResult.Err())
+}
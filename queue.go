@@ -4,11 +4,20 @@
 
 package influxdb2
 
-import "container/list"
+import (
+	"container/list"
+	"errors"
+	"sync/atomic"
+)
+
+// errBatchDiscarded is result of a batch removed from the full retry queue
+var errBatchDiscarded = errors.New("batch discarded from full retry queue")
 
 type queue struct {
 	list  *list.List
 	limit int
+	// total size of queued batches, accessed atomically
+	bytes int64
 }
 
 func newQueue(limit int) *queue {
@@ -17,10 +26,11 @@ func newQueue(limit int) *queue {
 func (q *queue) push(batch *batch) bool {
 	overWrite := false
 	if q.list.Len() == q.limit {
-		q.pop()
+		q.pop().resolve(errBatchDiscarded)
 		overWrite = true
 	}
 	q.list.PushBack(batch)
+	atomic.AddInt64(&q.bytes, int64(len(batch.batch)))
 	return overWrite
 }
 
@@ -28,7 +38,9 @@ func (q *queue) pop() *batch {
 	el := q.list.Front()
 	if el != nil {
 		q.list.Remove(el)
-		return el.Value.(*batch)
+		b := el.Value.(*batch)
+		atomic.AddInt64(&q.bytes, -int64(len(b.batch)))
+		return b
 	}
 	return nil
 }
@@ -41,3 +53,8 @@ func (q *queue) first() *batch {
 func (q *queue) isEmpty() bool {
 	return q.list.Len() == 0
 }
+
+// size returns total size of queued batches in bytes
+func (q *queue) size() int64 {
+	return atomic.LoadInt64(&q.bytes)
+}
@@ -10,8 +10,8 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/bonitoo-io/influxdb-client-go/domain"
-	"log"
 	"net/http"
+	"time"
 )
 
 func (c *client) Setup(ctx context.Context, username, password, org, bucket string, retentionPeriodHours int) (*domain.OnboardingResponse, error) {
@@ -31,9 +31,7 @@ func (c *client) Setup(ctx context.Context, username, password, org, bucket stri
 	if err != nil {
 		return nil, err
 	}
-	if c.options.LogLevel() > 2 {
-		log.Printf("D! Request:\n%s\n", string(inputData))
-	}
+	c.options.Logger().Debugf("Request:\n%s\n", string(inputData))
 	error := c.postRequest(ctx, c.serverUrl+"/api/v2/setup", bytes.NewReader(inputData), func(req *http.Request) {
 		req.Header.Add("Content-Type", "application/json; charset=utf-8")
 	},
@@ -45,7 +43,7 @@ func (c *client) Setup(ctx context.Context, username, password, org, bucket stri
 			}
 			setupResult = setupResponse
 			if setupResponse.Auth != nil && *setupResponse.Auth.Token != "" {
-				c.authorization = "Token " + *setupResponse.Auth.Token
+				c.authorization = authorization(c.options.AuthScheme(), *setupResponse.Auth.Token)
 			}
 			return nil
 		},
@@ -55,3 +53,17 @@ func (c *client) Setup(ctx context.Context, username, password, org, bucket stri
 	}
 	return setupResult, nil
 }
+
+// SetupRetention returns data retention period of the bucket created by Setup, as found in the setup response.
+// Zero duration means data never expires
+func SetupRetention(resp *domain.OnboardingResponse) (time.Duration, error) {
+	if resp == nil || resp.Bucket == nil {
+		return 0, errors.New("setup response has no bucket")
+	}
+	for _, rule := range resp.Bucket.RetentionRules {
+		if rule.Type == "expire" {
+			return time.Duration(rule.EverySeconds) * time.Second, nil
+		}
+	}
+	return 0, nil
+}
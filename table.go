@@ -5,7 +5,10 @@
 package influxdb2
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 )
@@ -62,15 +65,19 @@ func (f *FluxTableMetadata) Column(index int) *FluxColumn {
 	return f.columns[index]
 }
 
-// String returns FluxTableMetadata string dump
+// String returns single line summary of the table with its position and names and data types of columns
+// ordered by column index, e.g. table 0: result(string),_value(double)
 func (f *FluxTableMetadata) String() string {
+	columns := make([]*FluxColumn, len(f.columns))
+	copy(columns, f.columns)
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].index < columns[j].index })
 	var buffer strings.Builder
-	for i, c := range f.columns {
+	buffer.WriteString(fmt.Sprintf("table %d: ", f.position))
+	for i, c := range columns {
 		if i > 0 {
 			buffer.WriteString(",")
 		}
-		buffer.WriteString("col")
-		buffer.WriteString(c.String())
+		buffer.WriteString(fmt.Sprintf("%s(%s)", c.name, c.dataType))
 	}
 	return buffer.String()
 }
@@ -175,21 +182,212 @@ func (r *FluxRecord) Values() map[string]interface{} {
 	return r.values
 }
 
-// ValueByKey returns value for given column key for the record
+// ValuesInColumnOrder returns values of the record ordered by columns of the table metadata meta.
+// Value of a column missing in the record is nil
+func (r *FluxRecord) ValuesInColumnOrder(meta *FluxTableMetadata) []interface{} {
+	values := make([]interface{}, len(meta.Columns()))
+	for i, c := range meta.Columns() {
+		values[i] = r.values[c.Name()]
+	}
+	return values
+}
+
+// ValueByKey returns value for given column key for the record.
+// Value of a base64Binary column is decoded into []byte
 func (r *FluxRecord) ValueByKey(key string) interface{} {
 	return r.values[key]
 }
 
-// String returns FluxRecord string dump
+// ValueFloat returns the actual field value as float64.
+// Returns false if the record has no value or the value is of other type
+func (r *FluxRecord) ValueFloat() (float64, bool) {
+	return r.FloatByKey("_value")
+}
+
+// ValueInt returns the actual field value as int64.
+// Returns false if the record has no value or the value is of other type
+func (r *FluxRecord) ValueInt() (int64, bool) {
+	return r.IntByKey("_value")
+}
+
+// ValueUint returns the actual field value as uint64.
+// Returns false if the record has no value or the value is of other type
+func (r *FluxRecord) ValueUint() (uint64, bool) {
+	return r.UintByKey("_value")
+}
+
+// ValueBool returns the actual field value as bool.
+// Returns false as the second value if the record has no value or the value is of other type
+func (r *FluxRecord) ValueBool() (bool, bool) {
+	return r.BoolByKey("_value")
+}
+
+// ValueString returns the actual field value as string.
+// Returns false if the record has no value or the value is of other type
+func (r *FluxRecord) ValueString() (string, bool) {
+	return r.StringByKey("_value")
+}
+
+// FloatByKey returns value of the column key as float64.
+// Returns false if the column is missing or its value is of other type
+func (r *FluxRecord) FloatByKey(key string) (float64, bool) {
+	v, ok := r.values[key].(float64)
+	return v, ok
+}
+
+// IntByKey returns value of the column key as int64.
+// Returns false if the column is missing or its value is of other type
+func (r *FluxRecord) IntByKey(key string) (int64, bool) {
+	v, ok := r.values[key].(int64)
+	return v, ok
+}
+
+// UintByKey returns value of the column key as uint64.
+// Returns false if the column is missing or its value is of other type
+func (r *FluxRecord) UintByKey(key string) (uint64, bool) {
+	v, ok := r.values[key].(uint64)
+	return v, ok
+}
+
+// BoolByKey returns value of the column key as bool.
+// Returns false as the second value if the column is missing or its value is of other type
+func (r *FluxRecord) BoolByKey(key string) (bool, bool) {
+	v, ok := r.values[key].(bool)
+	return v, ok
+}
+
+// StringByKey returns value of the column key as string.
+// Returns false if the column is missing or its value is of other type
+func (r *FluxRecord) StringByKey(key string) (string, bool) {
+	v, ok := r.values[key].(string)
+	return v, ok
+}
+
+// BytesByKey returns value of the column key as []byte, columns of base64Binary type are decoded into bytes.
+// Returns false if the column is missing or its value is of other type
+func (r *FluxRecord) BytesByKey(key string) ([]byte, bool) {
+	v, ok := r.values[key].([]byte)
+	return v, ok
+}
+
+// String returns single line summary of the record as key=value pairs ordered by key, e.g. _field=f,_value=1.4
 func (r *FluxRecord) String() string {
+	keys := make([]string, 0, len(r.values))
+	for k := range r.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	var buffer strings.Builder
-	i := 0
-	for k, v := range r.values {
+	for i, k := range keys {
 		if i > 0 {
 			buffer.WriteString(",")
 		}
-		buffer.WriteString(fmt.Sprintf("%s:%v", k, v))
-		i++
+		buffer.WriteString(fmt.Sprintf("%s=%v", k, r.values[k]))
 	}
 	return buffer.String()
 }
+
+// Decode stores values of the record into the struct pointed to by dest.
+// Struct fields are matched to columns by the `influxdb:"column"` tag, e.g. `influxdb:"_value"`, or by the `flux:"column"` tag,
+// the influxdb tag takes precedence. Fields without a tag are matched to columns by name, case-insensitive and ignoring
+// leading underscores of the column name, e.g. field Value is matched to column _value.
+// Fields tagged "-", unexported fields and untagged fields without a matching column are left untouched,
+// a column of a tagged field missing in the record is an error.
+// Column value must be assignable to the field type or convertible to it without loss, i.e. numbers are decoded only
+// into fields of the same kind (signed integer, unsigned integer or float) able to hold the value
+func (r *FluxRecord) Decode(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("decode destination must be a non-nil pointer to a struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		value, ok := r.fieldValue(field)
+		if !ok {
+			if column, tagged := fieldColumn(field); tagged && column != "-" {
+				return fmt.Errorf("cannot decode field %s, column %s not found in table %d", field.Name, column, r.table)
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		if err := setFieldValue(v.Field(i), field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setFieldValue sets value to the struct field fv, value must be assignable or losslessly convertible to the field type
+func setFieldValue(fv reflect.Value, field reflect.StructField, value interface{}) error {
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Type().AssignableTo(fv.Type()):
+		fv.Set(rv)
+	case rv.Type().ConvertibleTo(fv.Type()) && kindClass(rv.Kind()) == kindClass(fv.Kind()):
+		if overflows(fv, rv) {
+			return fmt.Errorf("cannot decode value %v of type %s into field %s of type %s, value out of range", value, rv.Type(), field.Name, fv.Type())
+		}
+		fv.Set(rv.Convert(fv.Type()))
+	default:
+		return fmt.Errorf("cannot decode value %v of type %s into field %s of type %s", value, rv.Type(), field.Name, fv.Type())
+	}
+	return nil
+}
+
+// kindClass returns common kind of numeric kinds of the same class, e.g. reflect.Int for all signed integers
+func kindClass(kind reflect.Kind) reflect.Kind {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return kind
+}
+
+// overflows reports whether numeric value rv cannot be represented by the type of fv of the same kind class
+func overflows(fv, rv reflect.Value) bool {
+	switch kindClass(fv.Kind()) {
+	case reflect.Int:
+		return fv.OverflowInt(rv.Int())
+	case reflect.Uint:
+		return fv.OverflowUint(rv.Uint())
+	case reflect.Float64:
+		return fv.OverflowFloat(rv.Float())
+	}
+	return false
+}
+
+// fieldColumn returns column name of the struct field tagged by the influxdb or flux tag, false if the field has no tag
+func fieldColumn(field reflect.StructField) (string, bool) {
+	if column, ok := field.Tag.Lookup("influxdb"); ok {
+		return column, true
+	}
+	return field.Tag.Lookup("flux")
+}
+
+// fieldValue returns value of the column matching the struct field
+func (r *FluxRecord) fieldValue(field reflect.StructField) (interface{}, bool) {
+	if tag, ok := fieldColumn(field); ok {
+		if tag == "-" {
+			return nil, false
+		}
+		value, ok := r.values[tag]
+		return value, ok
+	}
+	for k, v := range r.values {
+		if strings.EqualFold(strings.TrimLeft(k, "_"), field.Name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestTable(t *testing.T) {
@@ -54,6 +55,8 @@ func TestTable(t *testing.T) {
 	assert.Equal(t, table.Column(4).Name(), "_field")
 	assert.Equal(t, table.Column(4).Index(), 4)
 	assert.Equal(t, table.Column(4).IsGroup(), true)
+
+	assert.Equal(t, "table 1: result(string),_table(long),_start(dateTime:RFC3339),_value(double),_field(string)", table.String())
 }
 
 func TestRecord(t *testing.T) {
@@ -79,4 +82,161 @@ func TestRecord(t *testing.T) {
 	assert.Equal(t, record.Value(), 1.4)
 	assert.Equal(t, record.Measurement(), "test")
 	assert.Equal(t, record.Table(), 2)
+	assert.Equal(t, "_field=f,_measurement=test,_start=2020-02-17 22:19:49.747562847 +0000 UTC,_stop=2020-02-18 22:19:49.747562847 +0000 UTC,"+
+		"_table=0,_time=2020-02-18 10:34:08.135814545 +0000 UTC,_value=1.4,a=1,b=adsfasdf,result=_result", record.String())
+}
+
+func TestRecordTypedValues(t *testing.T) {
+	record := &FluxRecord{table: 0,
+		values: map[string]interface{}{
+			"_value": 1.4,
+			"i":      int64(-2),
+			"u":      uint64(3),
+			"b":      true,
+			"s":      "str",
+			"bin":    []byte{0, 1},
+		},
+	}
+	f, ok := record.ValueFloat()
+	assert.True(t, ok)
+	assert.Equal(t, 1.4, f)
+	_, ok = record.ValueInt()
+	assert.False(t, ok)
+	_, ok = record.ValueUint()
+	assert.False(t, ok)
+	_, ok = record.ValueBool()
+	assert.False(t, ok)
+	_, ok = record.ValueString()
+	assert.False(t, ok)
+
+	i, ok := record.IntByKey("i")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-2), i)
+	u, ok := record.UintByKey("u")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), u)
+	b, ok := record.BoolByKey("b")
+	assert.True(t, ok)
+	assert.True(t, b)
+	s, ok := record.StringByKey("s")
+	assert.True(t, ok)
+	assert.Equal(t, "str", s)
+	_, ok = record.FloatByKey("i")
+	assert.False(t, ok)
+	_, ok = record.StringByKey("missing")
+	assert.False(t, ok)
+	bin, ok := record.BytesByKey("bin")
+	assert.True(t, ok)
+	assert.Equal(t, []byte{0, 1}, bin)
+	_, ok = record.BytesByKey("s")
+	assert.False(t, ok)
+
+	record.values["_value"] = int64(5)
+	i, ok = record.ValueInt()
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), i)
+	_, ok = record.ValueFloat()
+	assert.False(t, ok)
+}
+
+func TestRecordDecode(t *testing.T) {
+	record := &FluxRecord{table: 0,
+		values: map[string]interface{}{
+			"result":       "_result",
+			"table":        int64(3),
+			"_time":        mustParseTime("2020-02-18T10:34:08.135814545Z"),
+			"_value":       1.4,
+			"_field":       "f",
+			"_measurement": "test",
+			"a":            "1",
+		},
+	}
+	type row struct {
+		Time        time.Time
+		Value       float32
+		Measurement string `flux:"_measurement"`
+		Tag         string `flux:"a"`
+		Table       int
+		Field       string `flux:"-"`
+		Missing     string
+		hidden      string
+	}
+	var r row
+	require.Nil(t, record.Decode(&r))
+	assert.Equal(t, row{
+		Time:        mustParseTime("2020-02-18T10:34:08.135814545Z"),
+		Value:       1.4,
+		Measurement: "test",
+		Tag:         "1",
+		Table:       3,
+	}, r)
+
+	assert.NotNil(t, record.Decode(r))
+	var wrongType struct {
+		Value string
+	}
+	err := record.Decode(&wrongType)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode value 1.4 of type float64 into field Value of type string", err.Error())
+
+	var missing struct {
+		Host string `flux:"host"`
+	}
+	err = record.Decode(&missing)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode field Host, column host not found in table 0", err.Error())
+
+	// influxdb tag takes precedence over flux tag
+	var tagged struct {
+		Value       float64 `influxdb:"_value"`
+		Measurement string  `influxdb:"_measurement" flux:"_field"`
+		Field       string  `influxdb:"-" flux:"_field"`
+	}
+	require.Nil(t, record.Decode(&tagged))
+	assert.Equal(t, 1.4, tagged.Value)
+	assert.Equal(t, "test", tagged.Measurement)
+	assert.Equal(t, "", tagged.Field)
+}
+
+func TestRecordDecodeConversion(t *testing.T) {
+	record := &FluxRecord{values: map[string]interface{}{
+		"_value": 1.7,
+		"int":    int64(300),
+		"uint":   uint64(200),
+		"bool":   true,
+	}}
+	var fromFloat struct {
+		Value int
+	}
+	err := record.Decode(&fromFloat)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode value 1.7 of type float64 into field Value of type int", err.Error())
+	assert.Equal(t, 0, fromFloat.Value)
+
+	var overflow struct {
+		Int uint8
+	}
+	err = record.Decode(&overflow)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode value 300 of type int64 into field Int of type uint8", err.Error())
+
+	var narrow struct {
+		Int int8
+	}
+	err = record.Decode(&narrow)
+	require.NotNil(t, err)
+	assert.Equal(t, "cannot decode value 300 of type int64 into field Int of type int8, value out of range", err.Error())
+
+	var fromBool struct {
+		Bool int
+	}
+	require.NotNil(t, record.Decode(&fromBool))
+
+	var fits struct {
+		Int  int16
+		Uint uint8
+	}
+	require.Nil(t, record.Decode(&fits))
+	assert.Equal(t, int16(300), fits.Int)
+	assert.Equal(t, uint8(200), fits.Uint)
 }
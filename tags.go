@@ -0,0 +1,140 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"sort"
+	"strings"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// tagEscaper escapes tag key or value for line protocol
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// tagUnescaper removes line protocol escaping of tag key or value
+var tagUnescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ")
+
+// withDefaultTags returns copy of point with default tags, which are not already set on the point, added and tags sorted by key.
+// Given point is not modified
+func withDefaultTags(point *Point, defaultTags map[string]string) *Point {
+	tagged := *point
+	tagged.tags = make([]*lp.Tag, len(point.tags), len(point.tags)+len(defaultTags))
+	copy(tagged.tags, point.tags)
+	for k, v := range defaultTags {
+		if !hasTag(point, k) {
+			tagged.tags = append(tagged.tags, &lp.Tag{Key: k, Value: v})
+		}
+	}
+	return tagged.SortTags()
+}
+
+// hasTag returns true if point has tag with the key
+func hasTag(point *Point, key string) bool {
+	for _, t := range point.tags {
+		if t.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// lineTag is tag of line protocol record, with unescaped key and escaped key=value pair as it is written in the record
+type lineTag struct {
+	key string
+	raw string
+}
+
+// addDefaultTags returns line protocol record with default tags, which are not already present in the record, added into its tag set.
+// Tags of the record are then sorted by key. Multiple records separated by new line char are processed one by one.
+// Comments and records without fields are returned untouched
+func addDefaultTags(line string, defaultTags map[string]string) string {
+	if len(defaultTags) == 0 || strings.HasPrefix(line, "#") {
+		return line
+	}
+	if strings.Contains(line, "\n") {
+		records := strings.Split(line, "\n")
+		for i, record := range records {
+			records[i] = addDefaultTags(record, defaultTags)
+		}
+		return strings.Join(records, "\n")
+	}
+	measurementEnd, tagsEnd := -1, -1
+	for i := 0; i < len(line) && tagsEnd < 0; i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ',':
+			if measurementEnd < 0 {
+				measurementEnd = i
+			}
+		case ' ':
+			if measurementEnd < 0 {
+				measurementEnd = i
+			}
+			tagsEnd = i
+		}
+	}
+	if tagsEnd < 0 {
+		return line
+	}
+	var tags []lineTag
+	present := make(map[string]bool)
+	for _, raw := range splitEscaped(line[measurementEnd:tagsEnd], ',') {
+		if raw == "" {
+			continue
+		}
+		key := raw
+		if keyEnd := indexEscaped(raw, '='); keyEnd >= 0 {
+			key = raw[:keyEnd]
+		}
+		key = tagUnescaper.Replace(key)
+		present[key] = true
+		tags = append(tags, lineTag{key: key, raw: raw})
+	}
+	for k, v := range defaultTags {
+		if !present[k] {
+			tags = append(tags, lineTag{key: k, raw: tagEscaper.Replace(k) + "=" + tagEscaper.Replace(v)})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].key < tags[j].key })
+	var sb strings.Builder
+	sb.WriteString(line[:measurementEnd])
+	for _, t := range tags {
+		sb.WriteByte(',')
+		sb.WriteString(t.raw)
+	}
+	sb.WriteString(line[tagsEnd:])
+	return sb.String()
+}
+
+// splitEscaped splits s by separator sep, which is not escaped by backslash
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// indexEscaped returns index of the first c in s, which is not escaped by backslash, or -1 if there is none
+func indexEscaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDefaultTags(t *testing.T) {
+	defaultTags := map[string]string{"region": "eu west", "host": "h1"}
+	for _, c := range []struct {
+		line     string
+		expected string
+	}{
+		{"cpu f=1i 1", "cpu,host=h1,region=eu\\ west f=1i 1"},
+		{"cpu,zone=a,app=x f=1i", "cpu,app=x,host=h1,region=eu\\ west,zone=a f=1i"},
+		{"cpu,host=h2 f=1i 1", "cpu,host=h2,region=eu\\ west f=1i 1"},
+		{`c\ p\,u,t\=ag=v\,a\ l f="a b,c" 1`, `c\ p\,u,host=h1,region=eu\ west,t\=ag=v\,a\ l f="a b,c" 1`},
+		{"# comment", "# comment"},
+		{"cpu", "cpu"},
+		{"a f=1i\nb,host=h2 f=2i\n", "a,host=h1,region=eu\\ west f=1i\nb,host=h2,region=eu\\ west f=2i\n"},
+	} {
+		assert.Equal(t, c.expected, addDefaultTags(c.line, defaultTags))
+	}
+	assert.Equal(t, "cpu f=1i 1", addDefaultTags("cpu f=1i 1", nil))
+}
+
+func TestWriteDefaultTags(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.AddDefaultTag("region", "eu").AddDefaultTag("host", "h1")
+	assert.Equal(t, map[string]string{"region": "eu", "host": "h1"}, client.options.DefaultTags())
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+
+	p := NewPoint("cpu", map[string]string{"zone": "a", "host": "h2"}, map[string]interface{}{"f": 1}, time.Unix(0, 1))
+	require.Nil(t, writeApi.WritePoint(context.Background(), p, NewPointWithMeasurement("mem").AddField("f", 2).SetTime(time.Unix(0, 2))))
+	require.Nil(t, writeApi.WriteRecord(context.Background(), "disk,a=1 f=3i 3"))
+	assert.Equal(t, []string{
+		"cpu,host=h2,region=eu,zone=a f=1i 1",
+		"mem,host=h1,region=eu f=2i 2",
+		"disk,a=1,host=h1,region=eu f=3i 3",
+	}, client.Lines())
+	// point is not modified
+	assert.Len(t, p.TagList(), 2)
+
+	client.Close()
+	asyncApi := newWriteApiImpl("my-org", "my-bucket", client)
+	require.Nil(t, asyncApi.WriteRecord("disk f=4i 4"))
+	require.Nil(t, asyncApi.WritePoint(NewPointWithMeasurement("mem").AddTag("z", "1").AddField("f", 5).SetTime(time.Unix(0, 5))))
+	asyncApi.Close()
+	assert.Equal(t, []string{
+		"disk,host=h1,region=eu f=4i 4",
+		"mem,host=h1,region=eu,z=1 f=5i 5",
+	}, client.Lines())
+}
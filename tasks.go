@@ -0,0 +1,160 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+)
+
+// TasksApi provides methods for managing flux tasks
+type TasksApi interface {
+	// CreateTask creates a new task of the organization with id orgID from flux script, which must contain the task option,
+	// e.g. option task = {name: "downsample", every: 1h}. Returns the task as stored by the server, with its Id
+	CreateTask(ctx context.Context, flux string, orgID string) (*domain.Task, error)
+	// CreateTaskWithEvery creates a new task of the organization with id orgID named name, running flux query repeatedly
+	// after every interval, which is a flux duration literal, e.g. 1h. Flux must not contain the task option, it is created from the params
+	CreateTaskWithEvery(ctx context.Context, name, flux, every, orgID string) (*domain.Task, error)
+	// FindTasks returns tasks matching filter, nil filter means all tasks up to the server default limit
+	FindTasks(ctx context.Context, filter *TaskFilter) ([]domain.Task, error)
+	// DeleteTask deletes the task with the id
+	DeleteTask(ctx context.Context, id string) error
+	// RunManually schedules run of the task with the id immediately, regardless of its schedule, and returns the scheduled run
+	RunManually(ctx context.Context, taskID string) (*domain.Run, error)
+}
+
+// TaskFilter specifies tasks returned by TasksApi.FindTasks, empty properties are not applied
+type TaskFilter struct {
+	// Name is the exact task name
+	Name string
+	// OrgID is id of the organization owning tasks
+	OrgID string
+	// Limit is maximum count of returned tasks
+	Limit int
+}
+
+// tasksApiImpl implements TasksApi interface
+type tasksApiImpl struct {
+	client InfluxDBClient
+}
+
+func newTasksApiImpl(client InfluxDBClient) *tasksApiImpl {
+	return &tasksApiImpl{client: client}
+}
+
+func (t *tasksApiImpl) CreateTask(ctx context.Context, flux string, orgID string) (*domain.Task, error) {
+	tasksUrl, err := t.tasksUrl()
+	if err != nil {
+		return nil, err
+	}
+	var task domain.Task
+	if err := t.postJSON(ctx, tasksUrl.String(), &domain.TaskCreateRequest{Flux: flux, OrgID: &orgID}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (t *tasksApiImpl) CreateTaskWithEvery(ctx context.Context, name, flux, every, orgID string) (*domain.Task, error) {
+	return t.CreateTask(ctx, taskFlux(name, every, flux), orgID)
+}
+
+func (t *tasksApiImpl) FindTasks(ctx context.Context, filter *TaskFilter) ([]domain.Task, error) {
+	tasksUrl, err := t.tasksUrl()
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		params := url.Values{}
+		if filter.Name != "" {
+			params.Set("name", filter.Name)
+		}
+		if filter.OrgID != "" {
+			params.Set("orgID", filter.OrgID)
+		}
+		if filter.Limit > 0 {
+			params.Set("limit", strconv.Itoa(filter.Limit))
+		}
+		tasksUrl.RawQuery = params.Encode()
+	}
+	var tasks domain.Tasks
+	perror := t.client.getRequest(ctx, tasksUrl.String(), nil, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&tasks)
+	})
+	if perror != nil {
+		return nil, perror
+	}
+	if tasks.Tasks == nil {
+		return []domain.Task{}, nil
+	}
+	return *tasks.Tasks, nil
+}
+
+func (t *tasksApiImpl) DeleteTask(ctx context.Context, id string) error {
+	tasksUrl, err := t.tasksUrl(id)
+	if err != nil {
+		return err
+	}
+	perror := t.client.deleteRequest(ctx, tasksUrl.String(), nil, func(resp *http.Response) error {
+		return resp.Body.Close()
+	})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
+func (t *tasksApiImpl) RunManually(ctx context.Context, taskID string) (*domain.Run, error) {
+	runsUrl, err := t.tasksUrl(taskID, "runs")
+	if err != nil {
+		return nil, err
+	}
+	var run domain.Run
+	if err := t.postJSON(ctx, runsUrl.String(), &domain.RunManually{}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// postJSON sends body as JSON and decodes the response into result
+func (t *tasksApiImpl) postJSON(ctx context.Context, url string, body interface{}, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	perror := t.client.postRequest(ctx, url, bytes.NewReader(b), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+	}, func(resp *http.Response) error {
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(result)
+	})
+	if perror != nil {
+		return perror
+	}
+	return nil
+}
+
+// tasksUrl returns url of the tasks endpoint, extended by elems, e.g. task id
+func (t *tasksApiImpl) tasksUrl(elems ...string) (*url.URL, error) {
+	u, err := url.Parse(t.client.ServerUrl())
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(append([]string{u.Path, "/api/v2/tasks"}, elems...)...)
+	return u, nil
+}
+
+// taskFlux returns flux script of the task named name running query after every interval
+func taskFlux(name, every, query string) string {
+	return fmt.Sprintf("option task = {name: %s, every: %s}\n\n%s", fluxStringLiteral(name), every, query)
+}
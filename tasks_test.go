@@ -0,0 +1,134 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bonitoo-io/influxdb-client-go/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTasksApi(t *testing.T) {
+	tasks := make(map[string]domain.Task)
+	var ids []string
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		elems := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v2/tasks"), "/")
+		id := ""
+		if len(elems) > 1 {
+			id = elems[1]
+		}
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			queries = append(queries, r.URL.RawQuery)
+			list := make([]domain.Task, 0)
+			for _, id := range ids {
+				if task, ok := tasks[id]; ok && (r.URL.Query().Get("name") == "" || task.Name == r.URL.Query().Get("name")) {
+					list = append(list, task)
+				}
+			}
+			json.NewEncoder(w).Encode(domain.Tasks{Tasks: &list})
+		case r.Method == http.MethodPost && id == "":
+			var req domain.TaskCreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Content-Type") != "application/json" || req.OrgID == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if !strings.HasPrefix(req.Flux, "option task = {") {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":"invalid","message":"no task options defined"}`))
+				return
+			}
+			name := req.Flux[strings.Index(req.Flux, `name: "`)+7:]
+			name = name[:strings.Index(name, `"`)]
+			id = "t" + string(rune('0'+len(ids)))
+			ids = append(ids, id)
+			tasks[id] = domain.Task{Id: id, Name: name, Flux: req.Flux, OrgID: *req.OrgID}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(tasks[id])
+		case r.Method == http.MethodPost && len(elems) == 3 && elems[2] == "runs":
+			if _, ok := tasks[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			runId, status := "r1", "scheduled"
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(domain.Run{Id: &runId, TaskID: &id, Status: &status})
+		case r.Method == http.MethodDelete && id != "":
+			if _, ok := tasks[id]; !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"code":"not found","message":"task not found"}`))
+				return
+			}
+			delete(tasks, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	tasksApi := NewClient(server.URL, "my-token").TasksApi()
+	ctx := context.Background()
+
+	flux := `option task = {name: "downsample", every: 1h}
+
+from(bucket: "my-bucket") |> range(start: -task.every) |> aggregateWindow(every: 10m, fn: mean) |> to(bucket: "downsampled")`
+	task, err := tasksApi.CreateTask(ctx, flux, "org1")
+	require.Nil(t, err)
+	assert.Equal(t, "t0", task.Id)
+	assert.Equal(t, "downsample", task.Name)
+	assert.Equal(t, flux, task.Flux)
+	assert.Equal(t, "org1", task.OrgID)
+
+	task, err = tasksApi.CreateTaskWithEvery(ctx, `my "task"`, `from(bucket: "my-bucket") |> range(start: -1d)`, "1d", "org1")
+	require.Nil(t, err)
+	assert.Equal(t, "t1", task.Id)
+	assert.Equal(t, "option task = {name: \"my \\\"task\\\"\", every: 1d}\n\nfrom(bucket: \"my-bucket\") |> range(start: -1d)", task.Flux)
+
+	_, err = tasksApi.CreateTask(ctx, `from(bucket: "my-bucket")`, "org1")
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid: no task options defined", err.Error())
+
+	found, err := tasksApi.FindTasks(ctx, nil)
+	require.Nil(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "t0", found[0].Id)
+	found, err = tasksApi.FindTasks(ctx, &TaskFilter{Name: "downsample", OrgID: "org1", Limit: 10})
+	require.Nil(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "downsample", found[0].Name)
+	assert.Equal(t, []string{"", "limit=10&name=downsample&orgID=org1"}, queries)
+
+	run, err := tasksApi.RunManually(ctx, "t0")
+	require.Nil(t, err)
+	require.NotNil(t, run.Id)
+	require.NotNil(t, run.TaskID)
+	assert.Equal(t, "t0", *run.TaskID)
+	assert.Equal(t, "scheduled", *run.Status)
+
+	err = tasksApi.DeleteTask(ctx, "t0")
+	require.Nil(t, err)
+	found, err = tasksApi.FindTasks(ctx, nil)
+	require.Nil(t, err)
+	assert.Len(t, found, 1)
+
+	err = tasksApi.DeleteTask(ctx, "t0")
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, perror.StatusCode)
+
+	_, err = tasksApi.RunManually(ctx, "t0")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 InfluxData, Inc. All rights reserved.
+// Use of this source code is governed by MIT
+// license that can be found in the LICENSE file.
+
+package influxdb2
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templatePlaceholderRegexp matches {{name}} placeholder of a query template
+var templatePlaceholderRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// fluxStringEscaper escapes chars having special meaning inside of flux string literal, including string interpolation
+var fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`)
+
+// fluxStringLiteral returns s as quoted flux string literal
+func fluxStringLiteral(s string) string {
+	return `"` + fluxStringEscaper.Replace(s) + `"`
+}
+
+// executeTemplate replaces {{name}} placeholders in template with flux string literals of args values.
+// Returns error if template contains placeholder without value
+func executeTemplate(template string, args map[string]string) (string, error) {
+	var missing []string
+	query := templatePlaceholderRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := templatePlaceholderRegexp.FindStringSubmatch(placeholder)[1]
+		value, ok := args[name]
+		if !ok {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return fluxStringLiteral(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("query template placeholder(s) without value: %s", strings.Join(missing, ", "))
+	}
+	return query, nil
+}
@@ -5,61 +5,159 @@
 package influxdb2
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // WriteApiBlocking is Write client interface with non-blocking methods for writing time series data asynchronously in batches into an InfluxDB server.
 type WriteApi interface {
 	// WriteRecord writes asynchronously line protocol record into bucket.
+	// Line can contain also multiple records separated by new line char.
 	// WriteRecord adds record into the buffer which is sent on the background when it reaches the batch size.
-	// Blocking alternative is available in the WriteApiBlocking interface
-	WriteRecord(line string)
+	// Blocking alternative is available in the WriteApiBlocking interface.
+	// Returns ErrWriteApiClosed if the write client is already closed
+	WriteRecord(line string) error
 	// WritePoint writes asynchronously Point into bucket.
 	// WritePoint adds Point into the buffer which is sent on the background when it reaches the batch size.
-	// Blocking alternative is available in the WriteApiBlocking interface
-	WritePoint(point *Point)
+	// Blocking alternative is available in the WriteApiBlocking interface.
+	// Returns ErrWriteApiClosed if the write client is already closed
+	WritePoint(point *Point) error
+	// WriteBatchAt writes asynchronously points same as WritePoint, with timestamp ts set to points without timestamp.
+	// Points with a timestamp keep it. Given points are not modified
+	WriteBatchAt(ts time.Time, points ...*Point) error
+	// WritePointAsync writes asynchronously Point into bucket same as WritePoint and returns channel,
+	// which receives the result of writing the batch containing the point, once the batch is written or discarded
+	WritePointAsync(point *Point) <-chan error
+	// WritePointNow writes Point into bucket immediately, regardless of the batch size and the flush interval, and waits for the result.
+	// Point is written by the background writer, but it is not kept in the retry queue, failed write is returned and not retried
+	WritePointNow(ctx context.Context, point *Point) error
+	// WriteScanner reads line protocol records from the scanner s and writes them in batches of the batch size immediately,
+	// regardless of the flush interval, same as WritePointNow. onBatch, if set, is called with count of records of each batch
+	// after it is written, failed batches are not retried.
+	// Returns the first write error, or the scanner error after records read before it are written
+	WriteScanner(ctx context.Context, s *bufio.Scanner, onBatch func(n int)) error
 	// Flush forces all pending writes from the buffer to be sent
 	Flush()
+	// FlushMeasurement forces pending writes of the measurement to be sent. Other data stays in the buffer
+	FlushMeasurement(measurement string)
 	// Flushes all pending writes and stop async processes. After this the Write client cannot be used
 	Close()
 	// Errors return channel for reading errors which occurs during async writes
 	Errors() <-chan error
+	// Stats returns actual statistics of the write client
+	Stats() WriteStats
+	// BufferedCount returns number of records in the buffer waiting for sending as a batch
+	BufferedCount() int
+	// SetWriteSuccessCallback sets function called with each batch successfully written into the server
+	SetWriteSuccessCallback(callback WriteSuccessCallback)
+	// SetWriteFailedCallback sets function called with each batch, whose write failed, and the write error.
+	// The callback decides whether the batch is kept for retrying
+	SetWriteFailedCallback(callback WriteFailedCallback)
+	// SetProgressCallback sets function called with totals of written data each time at least everyBatches batches
+	// or everyPoints points were written since its last call. Zero interval is not applied, both zero mean to call it after each batch
+	SetProgressCallback(callback WriteProgressCallback, everyBatches, everyPoints uint)
+}
+
+// WriteSuccessCallback is called by the async write client with the line protocol content of a successfully written batch
+type WriteSuccessCallback func(batch string)
+
+// WriteFailedCallback is called by the async write client with the line protocol content of a batch, whose write failed,
+// the write error and count of already made retries of the batch.
+// Returning false discards the batch, true keeps it for retrying, if the error is retryable and MaxRetries is not exceeded
+type WriteFailedCallback func(batch string, err error, retryAttempt uint) bool
+
+// ErrWriteApiClosed is returned by write methods of the async write client after it was closed
+var ErrWriteApiClosed = errors.New("write client is closed")
+
+// WriteStats holds statistics of the async write client
+type WriteStats struct {
+	// BufferedBytes is size of data waiting in the write buffer and the retry queue
+	BufferedBytes uint
+	// DroppedRecords is count of records dropped because of exceeding Options.MaxBufferedBytes
+	DroppedRecords uint
+	// SeriesCardinality is count of distinct series written into each measurement. Tracked only if Options.CardinalityWarnThreshold is set
+	SeriesCardinality map[string]uint
 }
 
 type writeApiImpl struct {
+	// size of data in the write buffer and in the batch being written, accessed atomically
+	bufferedBytes int64
+	// count of records dropped because of full buffer, accessed atomically
+	droppedRecords int64
+	// count of records in writeBuffer, accessed atomically
+	bufferedCount int64
+
 	service     *writeService
 	writeBuffer []string
+	// result channels of lines in writeBuffer, nil for lines without result notification
+	writeBufferResults []chan error
+
+	url         string
+	writeCh     chan *batch
+	bufferCh    chan string
+	writeStop   chan int
+	bufferStop  chan int
+	bufferFlush chan int
+	// measurement, which buffered data are to be flushed
+	measurementFlush chan string
+	// closed is set by Close, writes hold read lock while sending data to the background goroutines
+	closed     bool
+	closedLock sync.RWMutex
+	doneCh     chan int
+	errCh      chan error
+	pointCh    chan *pointReq
+	writeNowCh chan *writeNowReq
+	// bufferSync and writeSync pass channel, which is closed once all data sent to the write proc before are written
+	bufferSync chan chan struct{}
+	writeSync  chan chan struct{}
+	// last timestamps of written series, used when adjusting duplicate timestamps
+	timestamps  seriesTimestamps
+	cardinality *cardinalityTracker
+	// ctx of all background writes, when it is done, batches are not written nor retried anymore
+	ctx context.Context
+}
 
-	url          string
-	writeCh      chan *batch
-	bufferCh     chan string
-	writeStop    chan int
-	bufferStop   chan int
-	bufferFlush  chan int
-	doneCh       chan int
-	errCh        chan error
-	bufferInfoCh chan writeBuffInfoReq
-	writeInfoCh  chan writeBuffInfoReq
+// writeNowReq is request for immediate write of a batch with channel for the write result
+type writeNowReq struct {
+	ctx    context.Context
+	batch  *batch
+	result chan error
 }
 
-type writeBuffInfoReq struct {
-	writeBuffLen int
+// pointReq is request for buffering a point in the buffer goroutine, with optional channel for the write result
+type pointReq struct {
+	point  *Point
+	result chan error
 }
 
 func newWriteApiImpl(org string, bucket string, client InfluxDBClient) *writeApiImpl {
+	return newWriteApiImplWithContext(context.Background(), org, bucket, client)
+}
+
+// newWriteApiImplWithContext creates writeApiImpl, which writes batches with ctx
+func newWriteApiImplWithContext(ctx context.Context, org string, bucket string, client InfluxDBClient) *writeApiImpl {
 	w := &writeApiImpl{
-		service:      newWriteService(org, bucket, client),
-		writeBuffer:  make([]string, 0, client.Options().BatchSize()+1),
-		writeCh:      make(chan *batch),
-		doneCh:       make(chan int),
-		bufferCh:     make(chan string),
-		bufferStop:   make(chan int),
-		writeStop:    make(chan int),
-		bufferFlush:  make(chan int),
-		bufferInfoCh: make(chan writeBuffInfoReq),
-		writeInfoCh:  make(chan writeBuffInfoReq),
+		ctx:              ctx,
+		service:          newWriteService(org, bucket, client),
+		writeBuffer:      make([]string, 0, client.Options().BatchSize()+1),
+		writeCh:          make(chan *batch),
+		doneCh:           make(chan int),
+		bufferCh:         make(chan string),
+		pointCh:          make(chan *pointReq),
+		writeNowCh:       make(chan *writeNowReq),
+		bufferStop:       make(chan int),
+		writeStop:        make(chan int),
+		bufferFlush:      make(chan int),
+		measurementFlush: make(chan string),
+		bufferSync:       make(chan chan struct{}),
+		writeSync:        make(chan chan struct{}),
+		timestamps:       make(seriesTimestamps),
+		cardinality:      newCardinalityTracker(),
 	}
 	go w.bufferProc()
 	go w.writeProc()
@@ -67,6 +165,18 @@ func newWriteApiImpl(org string, bucket string, client InfluxDBClient) *writeApi
 	return w
 }
 
+func (w *writeApiImpl) SetWriteSuccessCallback(callback WriteSuccessCallback) {
+	w.service.setWriteSuccessCallback(callback)
+}
+
+func (w *writeApiImpl) SetWriteFailedCallback(callback WriteFailedCallback) {
+	w.service.setWriteFailedCallback(callback)
+}
+
+func (w *writeApiImpl) SetProgressCallback(callback WriteProgressCallback, everyBatches, everyPoints uint) {
+	w.service.progress.set(callback, everyBatches, everyPoints)
+}
+
 func (w *writeApiImpl) Errors() <-chan error {
 	if w.errCh == nil {
 		w.errCh = make(chan error)
@@ -74,98 +184,204 @@ func (w *writeApiImpl) Errors() <-chan error {
 	return w.errCh
 }
 
-func (w *writeApiImpl) Flush() {
-	w.bufferFlush <- 1
-	w.waitForFlushing()
+func (w *writeApiImpl) Stats() WriteStats {
+	return WriteStats{
+		BufferedBytes:     uint(atomic.LoadInt64(&w.bufferedBytes) + w.service.retryQueue.size()),
+		DroppedRecords:    uint(atomic.LoadInt64(&w.droppedRecords)),
+		SeriesCardinality: w.cardinality.counts(),
+	}
 }
 
-func (w *writeApiImpl) waitForFlushing() {
-	for {
-		w.bufferInfoCh <- writeBuffInfoReq{}
-		writeBuffInfo := <-w.bufferInfoCh
-		if writeBuffInfo.writeBuffLen == 0 {
-			break
-		}
-		logger.Info("Waiting buffer is flushed")
-		time.Sleep(time.Millisecond)
+func (w *writeApiImpl) BufferedCount() int {
+	return int(atomic.LoadInt64(&w.bufferedCount))
+}
+
+// waitForBufferSpace blocks until size of buffered data is bellow MaxBufferedBytes.
+// Returns false if data should be dropped instead
+func (w *writeApiImpl) waitForBufferSpace() bool {
+	limit := w.service.client.Options().MaxBufferedBytes()
+	if limit == 0 {
+		return true
 	}
-	for {
-		w.writeInfoCh <- writeBuffInfoReq{}
-		writeBuffInfo := <-w.writeInfoCh
-		if writeBuffInfo.writeBuffLen == 0 {
-			break
+	for w.Stats().BufferedBytes >= limit {
+		if w.service.client.Options().DropOnBufferFull() {
+			atomic.AddInt64(&w.droppedRecords, 1)
+			w.service.logger().Warnf("Write buffer full, dropping data")
+			return false
 		}
-		logger.Info("Waiting buffer is flushed")
 		time.Sleep(time.Millisecond)
 	}
-	//time.Sleep(time.Millisecond)
+	return true
+}
+
+func (w *writeApiImpl) Flush() {
+	w.bufferFlush <- 1
+	w.waitForFlushing()
+}
+
+func (w *writeApiImpl) FlushMeasurement(measurement string) {
+	w.measurementFlush <- measurement
+	w.waitForFlushing()
+}
+
+// waitForFlushing blocks until all batches, which the buffer proc has sent to the write proc so far, are written.
+// The signal passes both procs in order after the data sent before, so no polling is needed
+func (w *writeApiImpl) waitForFlushing() {
+	done := make(chan struct{})
+	w.bufferSync <- done
+	<-done
 }
 
 func (w *writeApiImpl) bufferProc() {
-	logger.Info("Buffer proc started")
+	w.service.logger().Infof("Buffer proc started")
 	ticker := time.NewTicker(time.Duration(w.service.client.Options().FlushInterval()) * time.Millisecond)
 x:
 	for {
 		select {
 		case line := <-w.bufferCh:
-			w.writeBuffer = append(w.writeBuffer, line)
-			if len(w.writeBuffer) == int(w.service.client.Options().BatchSize()) {
-				w.flushBuffer()
-			}
+			w.bufferLine(line, nil)
+		case req := <-w.pointCh:
+			w.bufferPoint(req)
 		case <-ticker.C:
 			w.flushBuffer()
 		case <-w.bufferFlush:
 			w.flushBuffer()
+		case measurement := <-w.measurementFlush:
+			w.flushMeasurement(measurement)
 		case <-w.bufferStop:
 			ticker.Stop()
 			w.flushBuffer()
 			break x
-		case buffInfo := <-w.bufferInfoCh:
-			buffInfo.writeBuffLen = len(w.bufferInfoCh)
-			w.bufferInfoCh <- buffInfo
+		case done := <-w.bufferSync:
+			// write proc receives it after the batches already sent are written
+			w.writeSync <- done
 		}
 	}
-	logger.Info("Buffer proc finished")
+	w.service.logger().Infof("Buffer proc finished")
 	w.doneCh <- 1
 }
 
+// bufferPoint encodes point of the request and adds it to the buffer
+func (w *writeApiImpl) bufferPoint(req *pointReq) {
+	point := req.point
+	if w.service.client.Options().AdjustDuplicateTimestamps() {
+		// points are adjusted here, where it's known to which batch they belong
+		point = w.timestamps.adjust(point, w.service.client.Options().Precision())
+	}
+	line, err := w.service.encodePoints(point)
+	if err != nil {
+		w.logEncodingError(err)
+		notify(req.result, err)
+	} else if err := w.service.checkLineLength(line); err != nil {
+		w.rejectLine(err)
+		notify(req.result, err)
+	} else {
+		atomic.AddInt64(&w.bufferedBytes, int64(len(line)))
+		w.bufferLine(line, req.result)
+	}
+}
+
+// bufferLine adds line to the buffer, result is notified once the batch containing line is written
+func (w *writeApiImpl) bufferLine(line string, result chan error) {
+	w.writeBuffer = append(w.writeBuffer, line)
+	w.writeBufferResults = append(w.writeBufferResults, result)
+	atomic.StoreInt64(&w.bufferedCount, int64(len(w.writeBuffer)))
+	if len(w.writeBuffer) == int(w.service.client.Options().BatchSize()) {
+		w.flushBuffer()
+	}
+}
+
 func (w *writeApiImpl) flushBuffer() {
 	if len(w.writeBuffer) > 0 {
 		//go func(lines []string) {
-		logger.Info("sending batch")
-		batch := &batch{batch: buffer(w.writeBuffer)}
+		w.service.logger().Infof("sending batch")
+		batch := &batch{batch: buffer(w.writeBuffer), results: batchResults(w.writeBufferResults)}
 		w.writeCh <- batch
 		//	lines = lines[:0]
 		//}(w.writeBuffer)
 		//w.writeBuffer = make([]string,0, w.service.client.Options.BatchSize+1)
 		w.writeBuffer = w.writeBuffer[:0]
+		w.writeBufferResults = w.writeBufferResults[:0]
+		atomic.StoreInt64(&w.bufferedCount, 0)
+	}
+}
+
+// flushMeasurement sends buffered lines of the measurement as a batch and keeps other lines in the buffer
+func (w *writeApiImpl) flushMeasurement(measurement string) {
+	var lines []string
+	var results []chan error
+	rest := w.writeBuffer[:0]
+	restResults := w.writeBufferResults[:0]
+	for i, line := range w.writeBuffer {
+		if m, _ := lineSeries(line); measurementUnescaper.Replace(m) == measurement {
+			lines = append(lines, line)
+			results = append(results, w.writeBufferResults[i])
+		} else {
+			rest = append(rest, line)
+			restResults = append(restResults, w.writeBufferResults[i])
+		}
+	}
+	w.writeBuffer = rest
+	w.writeBufferResults = restResults
+	atomic.StoreInt64(&w.bufferedCount, int64(len(w.writeBuffer)))
+	if len(lines) > 0 {
+		w.service.logger().Infof("sending batch of measurement %s\n", measurement)
+		w.writeCh <- &batch{batch: buffer(lines), results: batchResults(results)}
 	}
 }
 
 func (w *writeApiImpl) writeProc() {
-	logger.Info("Write proc started")
+	w.service.logger().Infof("Write proc started")
 x:
 	for {
 		select {
 		case batch := <-w.writeCh:
-			err := w.service.handleWrite(context.Background(), batch)
+			err := w.service.handleWrite(w.ctx, batch)
+			if w.ctx.Err() != nil {
+				// batch and batches waiting for retry are discarded
+				w.discardBatches(batch, w.ctx.Err())
+			}
+			// batch is either written, discarded or kept in the retry queue, which counts its size on its own
+			atomic.AddInt64(&w.bufferedBytes, -int64(len(batch.batch)))
 			if err != nil && w.errCh != nil {
 				w.errCh <- err
 			}
+		case req := <-w.writeNowCh:
+			// batch is written directly, so that it isn't reported as written while it waits in the retry queue
+			req.result <- w.service.writeBatch(req.ctx, req.batch)
 		case <-w.writeStop:
-			logger.Info("Write proc: received stop")
+			w.service.logger().Infof("Write proc: received stop")
 			break x
-		case buffInfo := <-w.writeInfoCh:
-			buffInfo.writeBuffLen = len(w.writeCh)
-			w.writeInfoCh <- buffInfo
+		case done := <-w.writeSync:
+			close(done)
 		}
 	}
-	logger.Info("Write proc finished")
+	w.service.logger().Infof("Write proc finished")
 	w.doneCh <- 1
 }
 
+// discardBatches resolves batch and all batches waiting for retry with err, so they are not written anymore
+func (w *writeApiImpl) discardBatches(batch *batch, err error) {
+	batch.resolve(err)
+	for !w.service.retryQueue.isEmpty() {
+		w.service.retryQueue.pop().resolve(err)
+	}
+}
+
+// isClosed returns true if Close was called
+func (w *writeApiImpl) isClosed() bool {
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	return w.closed
+}
+
 func (w *writeApiImpl) Close() {
-	if w.writeCh != nil {
+	// wait for running writes and reject further ones
+	w.closedLock.Lock()
+	closed := w.closed
+	w.closed = true
+	w.closedLock.Unlock()
+	if !closed {
 		// Flush outstanding metrics
 		w.Flush()
 		w.bufferStop <- 1
@@ -173,19 +389,27 @@ func (w *writeApiImpl) Close() {
 		<-w.doneCh
 		close(w.bufferStop)
 		close(w.bufferFlush)
+		close(w.measurementFlush)
 		close(w.bufferCh)
+		close(w.pointCh)
 		w.writeStop <- 1
 		//wait for the write proc
 		<-w.doneCh
+		// batches waiting for retry are not written anymore
+		for !w.service.retryQueue.isEmpty() {
+			w.service.retryQueue.pop().resolve(ErrWriteApiClosed)
+		}
 		close(w.writeCh)
 		close(w.writeStop)
-		close(w.writeInfoCh)
-		close(w.bufferInfoCh)
-		w.bufferInfoCh = nil
-		w.writeInfoCh = nil
+		close(w.writeNowCh)
+		close(w.writeSync)
+		close(w.bufferSync)
+		w.bufferSync = nil
+		w.writeSync = nil
 		w.writeCh = nil
 		w.writeStop = nil
 		w.bufferFlush = nil
+		w.measurementFlush = nil
 		w.bufferStop = nil
 		if w.errCh != nil {
 			close(w.errCh)
@@ -194,22 +418,224 @@ func (w *writeApiImpl) Close() {
 	}
 }
 
-func (w *writeApiImpl) WriteRecord(line string) {
-	b := []byte(line)
-	b = append(b, 0xa)
-	w.bufferCh <- string(b)
+func (w *writeApiImpl) WriteRecord(line string) error {
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	if w.closed {
+		return ErrWriteApiClosed
+	}
+	// each record of multi-line input is buffered separately to be correctly counted into the batch size
+	for _, record := range strings.Split(line, "\n") {
+		if len(strings.TrimSpace(record)) == 0 {
+			continue
+		}
+		record = addDefaultTags(record, w.service.client.Options().DefaultTags())
+		if err := w.service.checkLineLength(record); err != nil {
+			w.rejectLine(err)
+			continue
+		}
+		if !w.waitForBufferSpace() {
+			continue
+		}
+		if threshold := w.service.client.Options().CardinalityWarnThreshold(); threshold > 0 {
+			measurement, series := lineSeries(record)
+			w.cardinality.add(measurement, series, threshold, w.service.logger())
+		}
+		record += "\n"
+		atomic.AddInt64(&w.bufferedBytes, int64(len(record)))
+		w.bufferCh <- record
+	}
+	return nil
 }
 
-func (w *writeApiImpl) WritePoint(point *Point) {
-	//w.bufferCh <- point.ToLineProtocol(w.service.client.Options().Precision)
+func (w *writeApiImpl) WriteBatchAt(ts time.Time, points ...*Point) error {
+	for _, point := range points {
+		if point.Time().IsZero() {
+			stamped := *point
+			stamped.timestamp = ts
+			point = &stamped
+		}
+		if err := w.WritePoint(point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writeApiImpl) WritePoint(point *Point) error {
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	if w.closed {
+		return ErrWriteApiClosed
+	}
+	if !w.waitForBufferSpace() {
+		return nil
+	}
+	if threshold := w.service.client.Options().CardinalityWarnThreshold(); threshold > 0 {
+		w.cardinality.add(point.Name(), seriesKey(point), threshold, w.service.logger())
+	}
+	if w.service.client.Options().AdjustDuplicateTimestamps() {
+		w.pointCh <- &pointReq{point: point}
+		return nil
+	}
 	line, err := w.service.encodePoints(point)
 	if err != nil {
-		logger.Errorf("point encoding error: %s\n", err.Error())
+		w.logEncodingError(err)
+	} else if err := w.service.checkLineLength(line); err != nil {
+		w.rejectLine(err)
 	} else {
+		atomic.AddInt64(&w.bufferedBytes, int64(len(line)))
 		w.bufferCh <- line
 	}
+	return nil
+}
+
+func (w *writeApiImpl) WritePointAsync(point *Point) <-chan error {
+	result := make(chan error, 1)
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	if w.closed {
+		result <- ErrWriteApiClosed
+		return result
+	}
+	if !w.waitForBufferSpace() {
+		result <- errors.New("write buffer full, point dropped")
+		return result
+	}
+	if threshold := w.service.client.Options().CardinalityWarnThreshold(); threshold > 0 {
+		w.cardinality.add(point.Name(), seriesKey(point), threshold, w.service.logger())
+	}
+	w.pointCh <- &pointReq{point: point, result: result}
+	return result
 }
 
+// batchResults returns copy of result channels of buffered lines without nil ones
+func batchResults(results []chan error) []chan error {
+	var nonNil []chan error
+	for _, r := range results {
+		if r != nil {
+			nonNil = append(nonNil, r)
+		}
+	}
+	return nonNil
+}
+
+// logEncodingError logs error of a point, which cannot be encoded and is not written.
+// Invalid point, without measurement or fields, is logged as a warning.
+// Point with too many fields, a field type conflict or timestamp out of range is rejected
+func (w *writeApiImpl) logEncodingError(err error) {
+	if errors.Is(err, ErrTooManyFields) || errors.Is(err, ErrFieldTypeConflict) || errors.Is(err, ErrTimestampOutOfRange) {
+		w.rejectLine(err)
+	} else if errors.Is(err, ErrEmptyMeasurement) || errors.Is(err, ErrNoFields) {
+		w.service.logger().Warnf("Point dropped: %s\n", err.Error())
+	} else {
+		w.service.logger().Errorf("point encoding error: %s\n", err.Error())
+	}
+}
+
+// rejectLine reports error of a record, which is not written
+func (w *writeApiImpl) rejectLine(err error) {
+	w.service.logger().Errorf("Record rejected: %s\n", err.Error())
+	if w.errCh != nil {
+		w.errCh <- err
+	}
+}
+
+func (w *writeApiImpl) WritePointNow(ctx context.Context, point *Point) error {
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	if w.closed {
+		return ErrWriteApiClosed
+	}
+	line, err := w.service.encodePoints(point)
+	if err != nil {
+		return err
+	}
+	if err := w.service.checkLineLength(line); err != nil {
+		return err
+	}
+	return w.writeNow(ctx, line)
+}
+
+func (w *writeApiImpl) WriteScanner(ctx context.Context, s *bufio.Scanner, onBatch func(n int)) error {
+	w.closedLock.RLock()
+	defer w.closedLock.RUnlock()
+	if w.closed {
+		return ErrWriteApiClosed
+	}
+	var sb strings.Builder
+	n := 0
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		if err := w.writeNow(ctx, sb.String()); err != nil {
+			return err
+		}
+		if onBatch != nil {
+			onBatch(n)
+		}
+		sb.Reset()
+		n = 0
+		return nil
+	}
+	for s.Scan() {
+		record := s.Text()
+		if len(strings.TrimSpace(record)) == 0 {
+			continue
+		}
+		record = addDefaultTags(record, w.service.client.Options().DefaultTags())
+		if err := w.service.checkLineLength(record); err != nil {
+			w.rejectLine(err)
+			continue
+		}
+		sb.WriteString(record)
+		sb.WriteString("\n")
+		n++
+		if uint(n) >= w.service.client.Options().BatchSize() {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return s.Err()
+}
+
+// writeNow sends line to the write proc to be written immediately and waits for the result
+func (w *writeApiImpl) writeNow(ctx context.Context, line string) error {
+	req := &writeNowReq{
+		ctx:    ctx,
+		batch:  &batch{batch: line, retryInterval: w.service.client.Options().RetryInterval(), direct: true},
+		result: make(chan error, 1),
+	}
+	select {
+	case w.writeNowCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return <-req.result
+}
+
+// buffer joins lines into a batch, ensuring each line is terminated by exactly one new line char.
+// Empty lines are skipped
 func buffer(lines []string) string {
-	return strings.Join(lines, "")
+	var sb strings.Builder
+	// single allocation of the batch
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+	sb.Grow(size)
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
 }
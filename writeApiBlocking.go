@@ -6,19 +6,108 @@ package influxdb2
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"strings"
+	"time"
 )
 
 // WriteApiBlocking offers blocking methods for writing time series data synchronously into an InfluxDB server.
 type WriteApiBlocking interface {
 	// WriteRecord writes line protocol record(s) into bucket.
-	// WriteRecord writes without implicit batching. Batch is created from given number of records
+	// Records are sent in requests of at most Options.BatchSize records, written sequentially until the first failure.
+	// Failure after some records were written is reported as *PartialWriteError
 	// Non-blocking alternative is available in the WriteApi interface
 	WriteRecord(ctx context.Context, line ...string) error
+	// WriteRecordWithPrecision writes line protocol record(s) into bucket same as WriteRecord, with timestamps of records
+	// in precision, which is one of time.Nanosecond, time.Microsecond, time.Millisecond or time.Second, instead of Options.Precision
+	WriteRecordWithPrecision(ctx context.Context, precision time.Duration, line ...string) error
 	// WritePoint data point into bucket.
-	// WritePoint writes without implicit batching. Batch is created from given number of points
+	// Points are sent in requests of at most Options.BatchSize points, written sequentially until the first failure.
+	// Failure after some points were written is reported as *PartialWriteError
 	// Non-blocking alternative is available in the WriteApi interface
 	WritePoint(ctx context.Context, point ...*Point) error
+	// EncodePoints returns line protocol of points with timestamps in precision, one of time.Nanosecond, time.Microsecond,
+	// time.Millisecond or time.Second, exactly as WritePoint would send it, without writing it. Useful for testing and logging
+	EncodePoints(precision time.Duration, point ...*Point) (string, error)
+	// WriteGzipped writes gzip compressed line protocol read from reader into bucket.
+	// Content is sent as it is, without decompressing, batching or retrying. Caller is responsible for its validity
+	WriteGzipped(ctx context.Context, reader io.Reader) error
+	// WriteRecordWithResult writes line protocol record(s) into bucket same as WriteRecord and returns also result of the write
+	WriteRecordWithResult(ctx context.Context, line ...string) (*WriteResult, error)
+	// WritePointWithResult writes data point(s) into bucket same as WritePoint and returns also result of the write
+	WritePointWithResult(ctx context.Context, point ...*Point) (*WriteResult, error)
+	// WritePointsChunked writes data points into bucket in batches of Options.BatchSize points.
+	// Failed batch doesn't stop writing of the following ones, errors of all failed batches are reported in the result.
+	// Batches are not retried, failed batch with a retryable status is reported as failed as well
+	WritePointsChunked(ctx context.Context, point ...*Point) *WritePointsResult
+	// SetProgressCallback sets function called with totals of written data each time at least everyBatches batches
+	// or everyPoints points were written since its last call. Zero interval is not applied, both zero mean to call it after each write
+	SetProgressCallback(callback WriteProgressCallback, everyBatches, everyPoints uint)
+}
+
+// WriteResult holds details of the successful blocking write
+type WriteResult struct {
+	// StatusCode is HTTP status code of the server response, e.g. 204. Zero if there was no data to write
+	StatusCode int
+}
+
+// WritePointsResult holds aggregated result of writing points in batches by WriteApiBlocking.WritePointsChunked
+type WritePointsResult struct {
+	// Written is count of points in successfully written batches
+	Written int
+	// Batches is count of batches the points were split into
+	Batches int
+	// Errors holds errors of failed batches in order of the batches
+	Errors []*BatchError
+}
+
+// FailedBatches returns count of failed batches
+func (r *WritePointsResult) FailedBatches() int {
+	return len(r.Errors)
+}
+
+// SuccessfulBatches returns count of successfully written batches
+func (r *WritePointsResult) SuccessfulBatches() int {
+	return r.Batches - len(r.Errors)
+}
+
+// BatchError is error of writing a single batch of points
+type BatchError struct {
+	// Batch is index of the batch
+	Batch int
+	// Points is count of points in the batch
+	Points int
+	// Err is the write error
+	Err error
+}
+
+// Error fulfils error interface
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch %d of %d points: %s", e.Batch, e.Points, e.Err.Error())
+}
+
+// Unwrap returns the write error
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// PartialWriteError is returned by blocking writes split into more requests, when a request fails after some were written
+type PartialWriteError struct {
+	// Written is count of line protocol records successfully written before the failure, merged points count as a single record
+	Written int
+	// Err is the write error
+	Err error
+}
+
+// Error fulfils error interface
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("write failed after %d record(s) written: %s", e.Written, e.Err.Error())
+}
+
+// Unwrap returns the write error
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
 }
 
 // writeApiBlockingImpl implements WriteApiBlocking interface
@@ -31,33 +120,214 @@ func newWriteApiBlockingImpl(org string, bucket string, client InfluxDBClient) *
 	return &writeApiBlockingImpl{service: newWriteService(org, bucket, client)}
 }
 
-func (w *writeApiBlockingImpl) write(ctx context.Context, line string) error {
-	err := w.service.handleWrite(ctx, &batch{
+// write writes line with timestamps in precision, nil precision means Options.WritePrecision.
+// Direct write sends the line immediately, regardless of the retry queue, and doesn't keep it for retrying on failure
+func (w *writeApiBlockingImpl) write(ctx context.Context, line string, precision *Precision, direct bool) (*WriteResult, error) {
+	b := &batch{
 		batch:         line,
 		retryInterval: w.service.client.Options().RetryInterval(),
-	})
-	return err
+		precision:     precision,
+		direct:        direct,
+	}
+	var err error
+	if direct {
+		err = w.service.writeBatch(ctx, b)
+	} else {
+		err = w.service.handleWrite(ctx, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &WriteResult{StatusCode: b.statusCode}, nil
+}
+
+// batchSize returns maximum count of records written in a single request for count records to write
+func (w *writeApiBlockingImpl) batchSize(count int) int {
+	if batchSize := int(w.service.client.Options().BatchSize()); batchSize > 0 {
+		return batchSize
+	}
+	return count
+}
+
+// writeBatches writes batches sequentially, stopping on the first failed one.
+// Failure after some batches were written is reported as *PartialWriteError with count of records of the written batches
+func (w *writeApiBlockingImpl) writeBatches(ctx context.Context, batches []string, precision *Precision, direct bool) (*WriteResult, error) {
+	result := &WriteResult{}
+	written := 0
+	for _, lines := range batches {
+		if len(lines) == 0 {
+			continue
+		}
+		var err error
+		result, err = w.write(ctx, lines, precision, direct)
+		if err != nil {
+			if written > 0 {
+				return nil, &PartialWriteError{Written: written, Err: err}
+			}
+			return nil, err
+		}
+		written += countLines(lines)
+	}
+	return result, nil
+}
+
+// countLines returns count of records in the batch of new line separated records
+func countLines(batch string) int {
+	return strings.Count(strings.TrimSuffix(batch, "\n"), "\n") + 1
+}
+
+// splitLines joins lines into batches of at most batchSize lines
+func splitLines(lines []string, batchSize int) []string {
+	batches := make([]string, 0, (len(lines)+batchSize-1)/batchSize)
+	for start := 0; start < len(lines); start += batchSize {
+		end := start + batchSize
+		if end > len(lines) {
+			end = len(lines)
+		}
+		batches = append(batches, buffer(lines[start:end]))
+	}
+	return batches
 }
 
 func (w *writeApiBlockingImpl) WriteRecord(ctx context.Context, line ...string) error {
+	_, err := w.WriteRecordWithResult(ctx, line...)
+	return err
+}
+
+func (w *writeApiBlockingImpl) WriteRecordWithPrecision(ctx context.Context, precision time.Duration, line ...string) error {
+	p, ok := precisionFromDuration(precision)
+	if !ok {
+		return fmt.Errorf("unsupported precision %s", precision)
+	}
+	_, err := w.writeRecords(ctx, &p, line)
+	return err
+}
+
+func (w *writeApiBlockingImpl) WriteRecordWithResult(ctx context.Context, line ...string) (*WriteResult, error) {
+	return w.writeRecords(ctx, nil, line)
+}
+
+// writeRecords writes line protocol records with timestamps in precision, nil precision means Options.WritePrecision
+func (w *writeApiBlockingImpl) writeRecords(ctx context.Context, precision *Precision, line []string) (*WriteResult, error) {
 	if len(line) > 0 {
-		var sb strings.Builder
+		valid := make([]string, 0, len(line))
+		var rejected []error
 		for _, line := range line {
-			b := []byte(line)
-			b = append(b, 0xa)
-			if _, err := sb.Write(b); err != nil {
-				return err
+			line = addDefaultTags(line, w.service.client.Options().DefaultTags())
+			if err := w.service.checkLineLength(line); err != nil {
+				rejected = append(rejected, err)
+				continue
 			}
+			valid = append(valid, line)
 		}
-		return w.write(ctx, sb.String())
+		return w.writeValid(ctx, valid, rejected, precision, false)
 	}
-	return nil
+	return &WriteResult{}, nil
 }
 
-func (w *writeApiBlockingImpl) WritePoint(ctx context.Context, point ...*Point) error {
-	line, err := w.service.encodePoints(point...)
+// writeValid writes lines, which passed validation, and reports rejected records as an error, if there are any
+func (w *writeApiBlockingImpl) writeValid(ctx context.Context, lines []string, rejected []error, precision *Precision, direct bool) (*WriteResult, error) {
+	batchSize := w.batchSize(len(lines))
+	result, err := w.writeBatches(ctx, splitLines(lines, batchSize), precision, direct)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return w.write(ctx, line)
+	if len(rejected) > 0 {
+		return result, fmt.Errorf("%d record(s) rejected: %s", len(rejected), rejected[0].Error())
+	}
+	return result, nil
+}
+
+func (w *writeApiBlockingImpl) WritePoint(ctx context.Context, point ...*Point) error {
+	_, err := w.WritePointWithResult(ctx, point...)
+	return err
+}
+
+func (w *writeApiBlockingImpl) EncodePoints(precision time.Duration, point ...*Point) (string, error) {
+	p, ok := precisionFromDuration(precision)
+	if !ok {
+		return "", fmt.Errorf("unsupported precision %s", precision)
+	}
+	return w.service.encodePointsWithPrecision(p, point...)
+}
+
+func (w *writeApiBlockingImpl) WritePointWithResult(ctx context.Context, point ...*Point) (*WriteResult, error) {
+	return w.writePoints(ctx, false, point)
+}
+
+// writePoints writes points in batches of at most Options.BatchSize points, see write for direct
+func (w *writeApiBlockingImpl) writePoints(ctx context.Context, direct bool, point []*Point) (*WriteResult, error) {
+	if len(point) == 0 {
+		return &WriteResult{}, nil
+	}
+	if w.service.client.Options().AdjustDuplicateTimestamps() {
+		timestamps := make(seriesTimestamps)
+		adjusted := make([]*Point, len(point))
+		for i, p := range point {
+			adjusted[i] = timestamps.adjust(p, w.service.client.Options().Precision())
+		}
+		point = adjusted
+	}
+	if w.service.client.Options().MaxLineBytes() > 0 {
+		// points are encoded together, so that duplicates are merged, and each line is validated then
+		lines, err := w.service.encodePoints(point...)
+		if err != nil {
+			return nil, err
+		}
+		valid := make([]string, 0, len(point))
+		var rejected []error
+		for _, line := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+			if err := w.service.checkLineLength(line); err != nil {
+				rejected = append(rejected, err)
+				continue
+			}
+			valid = append(valid, line)
+		}
+		return w.writeValid(ctx, valid, rejected, nil, direct)
+	}
+	// all batches are encoded before writing, so that invalid point prevents writing any of them
+	batchSize := w.batchSize(len(point))
+	batches := make([]string, 0, (len(point)+batchSize-1)/batchSize)
+	for start := 0; start < len(point); start += batchSize {
+		end := start + batchSize
+		if end > len(point) {
+			end = len(point)
+		}
+		line, err := w.service.encodePoints(point[start:end]...)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, line)
+	}
+	return w.writeBatches(ctx, batches, nil, direct)
+}
+
+func (w *writeApiBlockingImpl) WritePointsChunked(ctx context.Context, point ...*Point) *WritePointsResult {
+	result := &WritePointsResult{}
+	batchSize := int(w.service.client.Options().BatchSize())
+	if batchSize == 0 {
+		batchSize = len(point)
+	}
+	for start := 0; start < len(point); start += batchSize {
+		end := start + batchSize
+		if end > len(point) {
+			end = len(point)
+		}
+		result.Batches++
+		// chunks are written directly, chunk stored in the retry queue would be counted as written
+		if _, err := w.writePoints(ctx, true, point[start:end]); err != nil {
+			result.Errors = append(result.Errors, &BatchError{Batch: result.Batches - 1, Points: end - start, Err: err})
+			continue
+		}
+		result.Written += end - start
+	}
+	return result
+}
+
+func (w *writeApiBlockingImpl) SetProgressCallback(callback WriteProgressCallback, everyBatches, everyPoints uint) {
+	w.service.progress.set(callback, everyBatches, everyPoints)
+}
+
+func (w *writeApiBlockingImpl) WriteGzipped(ctx context.Context, reader io.Reader) error {
+	return w.service.writeGzipped(ctx, reader)
 }
@@ -5,9 +5,19 @@
 package influxdb2
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -52,7 +62,7 @@ func TestWriteRecord(t *testing.T) {
 	require.Nil(t, err)
 	require.Len(t, client.lines, 0)
 
-	client.replyError = &Error{Code: "invalid", Message: "data"}
+	client.SetReplyError(&Error{Code: "invalid", Message: "data"})
 	err = writeApi.WriteRecord(context.Background(), lines...)
 	require.NotNil(t, err)
 	require.Equal(t, "invalid: data", err.Error())
@@ -80,3 +90,550 @@ func TestWriteContextCancel(t *testing.T) {
 	require.Equal(t, context.Canceled, err)
 	assert.Len(t, client.lines, 0)
 }
+
+func TestWritePointAdjustDuplicateTimestamps(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetAdjustDuplicateTimestamps(true)
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	ts := time.Unix(0, 1000)
+	points := []*Point{
+		NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"temperature": 1.0}, ts),
+		NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"temperature": 2.0}, ts),
+		NewPoint("test", map[string]string{"id": "b"}, map[string]interface{}{"temperature": 3.0}, ts),
+		NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"temperature": 4.0}, ts),
+		NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"temperature": 5.0}, ts.Add(time.Nanosecond)),
+	}
+	err := writeApi.WritePoint(context.Background(), points...)
+	require.Nil(t, err)
+	expected := []string{
+		"test,id=a temperature=1 1000",
+		"test,id=a temperature=2 1001",
+		"test,id=b temperature=3 1000",
+		"test,id=a temperature=4 1002",
+		"test,id=a temperature=5 1003",
+	}
+	assert.Equal(t, expected, client.Lines())
+	// original points are kept untouched
+	assert.Equal(t, ts, points[1].Time())
+
+	client.Close()
+	client.options.SetAdjustDuplicateTimestamps(false)
+	err = writeApi.WritePoint(context.Background(), points[:2]...)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test,id=a temperature=1 1000", "test,id=a temperature=2 1000"}, client.Lines())
+}
+
+func TestWriteGzipped(t *testing.T) {
+	lines := genRecords(10)
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err := gw.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	require.Nil(t, err)
+	require.Nil(t, gw.Close())
+
+	var received []byte
+	var encoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		received, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "x")
+	writeApi := client.WriteApiBlocking("my-org", "my-bucket")
+
+	err = writeApi.WriteGzipped(context.Background(), bytes.NewReader(compressed.Bytes()))
+	require.Nil(t, err)
+	assert.Equal(t, "gzip", encoding)
+	// content is passed through without re-compression
+	assert.Equal(t, compressed.Bytes(), received)
+	gr, err := gzip.NewReader(bytes.NewReader(received))
+	require.Nil(t, err)
+	decompressed, err := ioutil.ReadAll(gr)
+	require.Nil(t, err)
+	assert.Equal(t, lines, strings.Split(strings.TrimSuffix(string(decompressed), "\n"), "\n"))
+}
+
+func TestWriteNoPoints(t *testing.T) {
+	requests := 0
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+		requestHandler: func(c *testClient, url string, body io.Reader) error {
+			requests++
+			return nil
+		},
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	err := writeApi.WritePoint(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, 0, requests)
+}
+
+func TestWriteRecordWithPrecision(t *testing.T) {
+	var precision string
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		precision = r.URL.Query().Get("precision")
+		body, _ := ioutil.ReadAll(r.Body)
+		lines = append(lines, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	writeApi := NewClient(server.URL, "my-token").WriteApiBlocking("my-org", "my-bucket")
+
+	err := writeApi.WriteRecordWithPrecision(context.Background(), time.Millisecond, "test a=1i 1588000000000", "test a=2i 1588000001000")
+	require.Nil(t, err)
+	assert.Equal(t, "ms", precision)
+	assert.Equal(t, []string{"test a=1i 1588000000000", "test a=2i 1588000001000"}, lines)
+
+	// other writes use client precision
+	err = writeApi.WriteRecord(context.Background(), "test a=3i 1588000002000000000")
+	require.Nil(t, err)
+	assert.Equal(t, "ns", precision)
+
+	err = writeApi.WriteRecordWithPrecision(context.Background(), time.Second, "test a=4i 1588000003")
+	require.Nil(t, err)
+	assert.Equal(t, "s", precision)
+	assert.Len(t, lines, 4)
+
+	err = writeApi.WriteRecordWithPrecision(context.Background(), time.Minute, "test a=5i 1")
+	require.NotNil(t, err)
+	assert.Equal(t, "unsupported precision 1m0s", err.Error())
+	assert.Len(t, lines, 4)
+}
+
+func TestEncodePoints(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.AddDefaultTag("dc", "x")
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	ts := time.Unix(60, 5000000)
+	points := []*Point{
+		NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"f": 1.5, "u": uint64(2)}, ts),
+		NewPoint("test", map[string]string{"id": "b"}, map[string]interface{}{"i": 3}, ts),
+	}
+
+	lines, err := writeApi.EncodePoints(time.Millisecond, points...)
+	require.Nil(t, err)
+	assert.Equal(t, "test,dc=x,id=a f=1.5,u=2u 60005\ntest,dc=x,id=b i=3i 60005\n", lines)
+	assert.Len(t, client.Lines(), 0)
+
+	lines, err = writeApi.EncodePoints(time.Second, points[1])
+	require.Nil(t, err)
+	assert.Equal(t, "test,dc=x,id=b i=3i 60\n", lines)
+
+	// encoding errors are the same as of writing
+	_, err = writeApi.EncodePoints(time.Second, NewPointWithMeasurement("test").AddField("f", math.Inf(1)))
+	require.NotNil(t, err)
+	assert.Equal(t, "point test, field f=+Inf: is Inf", err.Error())
+
+	_, err = writeApi.EncodePoints(time.Hour, points...)
+	require.NotNil(t, err)
+	assert.Equal(t, "unsupported precision 1h0m0s", err.Error())
+}
+
+func TestWriteInvalidPoints(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	valid := NewPointWithMeasurement("test").AddField("a", 1)
+
+	err := writeApi.WritePoint(context.Background(), valid, NewPointWithMeasurement("").AddField("a", 1))
+	require.NotNil(t, err)
+	assert.Equal(t, ErrEmptyMeasurement, err)
+
+	err = writeApi.WritePoint(context.Background(), valid, NewPointWithMeasurement("test").AddTag("id", "a"))
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrNoFields))
+	assert.Equal(t, "point test: point has no fields", err.Error())
+	assert.Len(t, client.Lines(), 0)
+}
+
+func TestWriteMaxFieldsPerPoint(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions().SetMaxFieldsPerPoint(2),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+
+	err := writeApi.WritePoint(context.Background(), NewPointWithMeasurement("test").AddField("a", 1).AddField("b", 2).AddField("c", 3))
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrTooManyFields))
+	assert.Equal(t, "point test: point has too many fields, 3 fields exceed maximum of 2", err.Error())
+	assert.Len(t, client.Lines(), 0)
+
+	err = writeApi.WritePoint(context.Background(), NewPointWithMeasurement("test").AddField("a", 1).AddField("b", 2))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test a=1i,b=2i"}, client.Lines())
+}
+
+func TestWriteFieldTypePolicy(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions().SetFieldTypePolicy(FieldTypePolicyCoerce),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	ctx := context.Background()
+
+	require.Nil(t, writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", 1).SetTime(time.Unix(0, 1))))
+	require.Nil(t, writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", 2.0).SetTime(time.Unix(0, 2))))
+	// other measurement has its own field types
+	require.Nil(t, writeApi.WritePoint(ctx, NewPointWithMeasurement("other").AddField("x", 2.5).SetTime(time.Unix(0, 3))))
+	err := writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", 2.5).SetTime(time.Unix(0, 4)))
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrFieldTypeConflict))
+	assert.Equal(t, "point test: field type conflict, field x value 2.5 is float, but the field is integer", err.Error())
+	err = writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", "2").SetTime(time.Unix(0, 5)))
+	require.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrFieldTypeConflict))
+	err = writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", true).SetTime(time.Unix(0, 5)))
+	require.NotNil(t, err)
+	// type names are the same as of FieldType
+	assert.Equal(t, "point test: field type conflict, field x value true is "+FieldTypeBool.String()+", but the field is integer", err.Error())
+	require.Nil(t, writeApi.WritePoint(ctx, NewPointWithMeasurement("other").AddField("x", 3).SetTime(time.Unix(0, 6))))
+	assert.Equal(t, []string{"test x=1i 1", "test x=2i 2", "other x=2.5 3", "other x=3 6"}, client.Lines())
+
+	client.Close()
+	client.options.SetFieldTypePolicy(FieldTypePolicyReject)
+	writeApi = newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	require.Nil(t, writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", 1).SetTime(time.Unix(0, 1))))
+	err = writeApi.WritePoint(ctx, NewPointWithMeasurement("test").AddField("x", 2.0).SetTime(time.Unix(0, 2)))
+	require.NotNil(t, err)
+	assert.Equal(t, "point test: field type conflict, field x value 2 is float, but the field is integer", err.Error())
+	assert.Equal(t, []string{"test x=1i 1"}, client.Lines())
+}
+
+func TestWriteBlockingInBatches(t *testing.T) {
+	var requests []string
+	failAt := 0
+	client := &testClient{
+		options: DefaultOptions().SetBatchSize(2).SetMaxRetries(0),
+		t:       t,
+		requestHandler: func(c *testClient, url string, reader io.Reader) error {
+			b, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			requests = append(requests, string(b))
+			if len(requests) == failAt {
+				return errors.New("request too large")
+			}
+			return nil
+		},
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	lines := []string{"test a=1 1", "test a=2 2", "test a=3 3", "test a=4 4", "test a=5 5"}
+
+	err := writeApi.WriteRecord(context.Background(), lines...)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test a=1 1\ntest a=2 2\n", "test a=3 3\ntest a=4 4\n", "test a=5 5\n"}, requests)
+
+	requests = nil
+	failAt = 2
+	err = writeApi.WriteRecord(context.Background(), lines...)
+	require.NotNil(t, err)
+	var partialErr *PartialWriteError
+	require.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 2, partialErr.Written)
+	assert.Equal(t, "write failed after 2 record(s) written: request too large", err.Error())
+	assert.Len(t, requests, 2)
+
+	points := make([]*Point, 5)
+	for i := range points {
+		points[i] = NewPointWithMeasurement("test").AddField("a", i+1).SetTime(time.Unix(0, int64(i+1)))
+	}
+	requests = nil
+	failAt = 3
+	err = writeApi.WritePoint(context.Background(), points...)
+	require.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 4, partialErr.Written)
+	assert.Equal(t, []string{"test a=1i 1\ntest a=2i 2\n", "test a=3i 3\ntest a=4i 4\n", "test a=5i 5\n"}, requests)
+
+	requests = nil
+	failAt = 1
+	err = writeApi.WritePoint(context.Background(), points...)
+	require.NotNil(t, err)
+	assert.False(t, errors.As(err, &partialErr))
+	assert.Len(t, requests, 1)
+
+	// only records of written batches are counted, empty lines are skipped
+	requests = nil
+	failAt = 2
+	err = writeApi.WriteRecord(context.Background(), "test a=1 1", "", "test a=3 3", "test a=4 4")
+	require.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 1, partialErr.Written)
+	assert.Equal(t, []string{"test a=1 1\n", "test a=3 3\ntest a=4 4\n"}, requests)
+
+	// merged points are counted as a single record
+	requests = nil
+	failAt = 2
+	client.options.SetMergeDuplicatePoints(true)
+	merged := []*Point{
+		NewPointWithMeasurement("test").AddField("a", 1).SetTime(time.Unix(0, 1)),
+		NewPointWithMeasurement("test").AddField("b", 1).SetTime(time.Unix(0, 1)),
+		NewPointWithMeasurement("test").AddField("a", 3).SetTime(time.Unix(0, 3)),
+	}
+	err = writeApi.WritePoint(context.Background(), merged...)
+	require.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 1, partialErr.Written)
+	assert.Equal(t, []string{"test a=1i,b=1i 1\n", "test a=3i 3\n"}, requests)
+	client.options.SetMergeDuplicatePoints(false)
+
+	// invalid point prevents writing any batch
+	requests = nil
+	failAt = 0
+	err = writeApi.WritePoint(context.Background(), append(points, NewPointWithMeasurement("test"))...)
+	require.True(t, errors.Is(err, ErrNoFields))
+	assert.Len(t, requests, 0)
+}
+
+func TestWriteTimestampOutOfRange(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	for _, ts := range []time.Time{
+		time.Date(1500, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		for _, precision := range []time.Duration{time.Nanosecond, time.Second} {
+			client.options.SetPrecision(precision)
+			err := writeApi.WritePoint(context.Background(), NewPointWithMeasurement("test").AddField("a", 1).SetTime(ts))
+			require.NotNil(t, err)
+			assert.True(t, errors.Is(err, ErrTimestampOutOfRange))
+			assert.True(t, strings.HasPrefix(err.Error(), "point test: point timestamp out of range, "+ts.Format(time.RFC3339)), err.Error())
+		}
+	}
+	assert.Len(t, client.Lines(), 0)
+
+	_, err := writeApi.EncodePoints(time.Second, NewPointWithMeasurement("test").AddField("a", 1).SetTime(time.Date(1500, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, errors.Is(err, ErrTimestampOutOfRange))
+
+	// limits are inclusive
+	lines, err := writeApi.EncodePoints(time.Nanosecond,
+		NewPointWithMeasurement("test").AddField("a", 1).SetTime(minTimestamp),
+		NewPointWithMeasurement("test").AddField("a", 2).SetTime(maxTimestamp))
+	require.Nil(t, err)
+	assert.Equal(t, "test a=1i -9223372036854775808\ntest a=2i 9223372036854775807\n", lines)
+}
+
+func TestWriteOmitTrailingNewline(t *testing.T) {
+	var body string
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+		requestHandler: func(c *testClient, url string, reader io.Reader) error {
+			b, err := ioutil.ReadAll(reader)
+			body = string(b)
+			return err
+		},
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	lines := genRecords(3)
+	err := writeApi.WriteRecord(context.Background(), lines...)
+	require.Nil(t, err)
+	assert.Equal(t, strings.Join(lines, "\n")+"\n", body)
+
+	client.options.SetOmitTrailingNewline(true)
+	err = writeApi.WriteRecord(context.Background(), lines...)
+	require.Nil(t, err)
+	assert.Equal(t, strings.Join(lines, "\n"), body)
+}
+
+func TestWriteWithResult(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "x")
+	writeApi := client.WriteApiBlocking("my-org", "my-bucket")
+
+	res, err := writeApi.WritePointWithResult(context.Background(), genPoints(2)...)
+	require.Nil(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	status = http.StatusOK
+	res, err = writeApi.WriteRecordWithResult(context.Background(), genRecords(2)...)
+	require.Nil(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = writeApi.WritePointWithResult(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, 0, res.StatusCode)
+
+	status = http.StatusBadRequest
+	res, err = writeApi.WriteRecordWithResult(context.Background(), genRecords(2)...)
+	require.NotNil(t, err)
+	assert.Nil(t, res)
+}
+
+func TestWriteMaxLineBytes(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetMaxLineBytes(100)
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	long := fmt.Sprintf(`test,id=1 s="%s"`, strings.Repeat("x", 100))
+	err := writeApi.WriteRecord(context.Background(), "test,id=0 f=1i", long, "test,id=2 f=2i")
+	require.NotNil(t, err)
+	assert.Equal(t, "1 record(s) rejected: line protocol record of 114 bytes exceeds maximum length 100 bytes", err.Error())
+	assert.Equal(t, []string{"test,id=0 f=1i", "test,id=2 f=2i"}, client.Lines())
+
+	client.Close()
+	points := []*Point{
+		NewPoint("test", map[string]string{"id": "0"}, map[string]interface{}{"f": 1}, time.Unix(0, 10)),
+		NewPoint("test", map[string]string{"id": "1"}, map[string]interface{}{"s": strings.Repeat("x", 100)}, time.Unix(0, 10)),
+	}
+	err = writeApi.WritePoint(context.Background(), points...)
+	require.NotNil(t, err)
+	assert.Equal(t, []string{"test,id=0 f=1i 10"}, client.Lines())
+
+	client.Close()
+	err = writeApi.WritePoint(context.Background(), points[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test,id=0 f=1i 10"}, client.Lines())
+
+	// duplicate points are merged before the line length check
+	client.Close()
+	client.options.SetMergeDuplicatePoints(true)
+	err = writeApi.WritePoint(context.Background(),
+		NewPoint("test", map[string]string{"id": "0"}, map[string]interface{}{"f": 1}, time.Unix(0, 10)),
+		NewPoint("test", map[string]string{"id": "0"}, map[string]interface{}{"g": 2}, time.Unix(0, 10)),
+		NewPoint("test", map[string]string{"id": "0"}, map[string]interface{}{"s": strings.Repeat("x", 100)}, time.Unix(0, 20)),
+	)
+	require.NotNil(t, err)
+	assert.Equal(t, []string{"test,id=0 f=1i,g=2i 10"}, client.Lines())
+}
+
+func TestWriteCoerceFieldsToFloat(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetCoerceFieldsToFloat(true)
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	p := NewPoint("test", nil, map[string]interface{}{"i": 1, "u": uint(2), "f": 3.5, "s": "a", "b": true}, time.Unix(0, 10))
+	p.SortFields()
+	require.Nil(t, writeApi.WritePoint(context.Background(), p))
+	require.Len(t, client.Lines(), 1)
+	assert.Equal(t, `test b=true,f=3.5,i=1,s="a",u=2 10`, client.Lines()[0])
+	// point itself is not modified
+	assert.Equal(t, int64(1), p.FieldList()[2].Value)
+
+	client.Close()
+	client.options.SetCoerceFieldsToFloat(false)
+	require.Nil(t, writeApi.WritePoint(context.Background(), p))
+	require.Len(t, client.Lines(), 1)
+	assert.Equal(t, `test b=true,f=3.5,i=1i,s="a",u=2u 10`, client.Lines()[0])
+}
+
+func TestWriteUnauthorized(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetMaxRetries(3)
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	client.SetReplyError(&Error{
+		StatusCode: 403,
+		Code:       "forbidden",
+		Message:    "insufficient permissions for write",
+	})
+	err := writeApi.WriteRecord(context.Background(), "test,a=1 f=1i 1")
+	require.NotNil(t, err)
+	assert.Equal(t, "forbidden: token not authorized for bucket my-bucket in org my-org: insufficient permissions for write", err.Error())
+	assert.True(t, writeApi.service.retryQueue.isEmpty())
+	assert.Len(t, client.Lines(), 0)
+}
+
+func TestWriteRecordSeparators(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	err := writeApi.WriteRecord(context.Background(), "test,a=1 f=1i 1\n", "test,a=2 f=2i 2", "test,a=3 f=3i 3\n")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"test,a=1 f=1i 1", "test,a=2 f=2i 2", "test,a=3 f=3i 3"}, client.Lines())
+}
+
+func TestWritePointsChunked(t *testing.T) {
+	requests := 0
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+		requestHandler: func(c *testClient, url string, body io.Reader) error {
+			requests++
+			if requests == 2 {
+				return fmt.Errorf("connection reset")
+			}
+			return c.decodeLines(body)
+		},
+	}
+	client.options.SetBatchSize(4)
+	writeApi := newWriteApiBlockingImpl("my-org", "my-bucket", client)
+	points := genPoints(10)
+
+	result := writeApi.WritePointsChunked(context.Background(), points...)
+	assert.Equal(t, 3, result.Batches)
+	assert.Equal(t, 6, result.Written)
+	assert.Equal(t, 2, result.SuccessfulBatches())
+	assert.Equal(t, 1, result.FailedBatches())
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 1, result.Errors[0].Batch)
+	assert.Equal(t, 4, result.Errors[0].Points)
+	assert.Equal(t, "batch 1 of 4 points: connection reset", result.Errors[0].Error())
+	require.Len(t, client.Lines(), 6)
+	assert.True(t, strings.HasPrefix(client.Lines()[3], "test,hostname=host_3"))
+	assert.True(t, strings.HasPrefix(client.Lines()[4], "test,hostname=host_8"))
+
+	result = writeApi.WritePointsChunked(context.Background())
+	assert.Equal(t, &WritePointsResult{}, result)
+}
+
+func TestWritePointsChunkedRetryableError(t *testing.T) {
+	var requests int
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		lines = append(lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := NewClientWithOptions(server.URL, "x", DefaultOptions().SetBatchSize(4))
+	writeApi := client.WriteApiBlocking("my-org", "my-bucket")
+
+	result := writeApi.WritePointsChunked(context.Background(), genPoints(12)...)
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 3, result.Batches)
+	assert.Equal(t, 8, result.Written)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 1, result.Errors[0].Batch)
+	var perror *Error
+	require.True(t, errors.As(result.Errors[0], &perror))
+	assert.Equal(t, http.StatusServiceUnavailable, perror.StatusCode)
+	assert.Len(t, lines, 8)
+
+	// failed chunk is not kept for retrying by the following writes
+	require.Nil(t, writeApi.WritePoint(context.Background(), genPoints(1)...))
+	assert.Equal(t, 4, requests)
+	assert.Len(t, lines, 9)
+}
@@ -7,25 +7,154 @@ package influxdb2
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bonitoo-io/influxdb-client-go/internal/gzip"
-	"github.com/bonitoo-io/influxdb-client-go/internal/log"
 	lp "github.com/influxdata/line-protocol"
 )
 
-var logger log.Logger
+// idempotencyKeyHeader is the request header identifying a write batch for deduplication of retries
+const idempotencyKeyHeader = "Idempotency-Key"
 
 type batch struct {
 	batch         string
 	retryInterval uint
 	retries       uint
+	// precision of timestamps of the batch records, nil means Options.WritePrecision
+	precision *Precision
+	// status code of the successful write response
+	statusCode int
+	// channels receiving the final result of writing the batch
+	results []chan error
+	// direct batch is not kept in the retry queue when its write fails
+	direct bool
+}
+
+// resolve sends the final result of writing the batch to all its result channels
+func (b *batch) resolve(err error) {
+	for _, r := range b.results {
+		notify(r, err)
+	}
+	b.results = nil
+}
+
+// notify sends err to result channel, if set. Result channel must have space for the value
+func notify(result chan error, err error) {
+	if result != nil {
+		result <- err
+	}
+}
+
+// maxTimestampSeries limits count of series, whose last timestamps are kept by seriesTimestamps
+const maxTimestampSeries = 10000
+
+// seriesTimestamps holds the last run of timestamps, in units of precision, used by each series of points.
+// It is kept across batches, so that points in consecutive batches don't reuse already adjusted timestamps
+type seriesTimestamps map[string]timestampRun
+
+// timestampRun is a range of timestamps used by a series, first is the original timestamp of the run's points
+type timestampRun struct {
+	first, last int64
+}
+
+// adjust returns point with timestamp shifted forward by precision after the last timestamp used by other point
+// of the same series, if the point's timestamp falls into the series' last run. Out of order points are not adjusted.
+// Given point is not modified, shifted copy is returned instead
+func (s seriesTimestamps) adjust(point *Point, precision time.Duration) *Point {
+	if point.Time().IsZero() {
+		return point
+	}
+	key := seriesKey(point)
+	ts := point.Time().UnixNano() / int64(precision)
+	run, ok := s[key]
+	switch {
+	case !ok || ts > run.last:
+		if !ok && len(s) >= maxTimestampSeries {
+			// drop an arbitrary series to keep the memory bounded
+			for k := range s {
+				delete(s, k)
+				break
+			}
+		}
+		s[key] = timestampRun{first: ts, last: ts}
+		return point
+	case ts < run.first:
+		return point
+	}
+	run.last++
+	s[key] = run
+	adjusted := *point
+	adjusted.timestamp = time.Unix(0, run.last*int64(precision))
+	return &adjusted
+}
+
+// seriesKey returns identification of the point's series composed of measurement and sorted tags
+func seriesKey(point *Point) string {
+	tags := make([]string, len(point.TagList()))
+	for i, tag := range point.TagList() {
+		tags[i] = tag.Key + "=" + tag.Value
+	}
+	sort.Strings(tags)
+	return point.Name() + "," + strings.Join(tags, ",")
+}
+
+// mergeDuplicatePoints returns points where points with the same series and timestamp, in units of precision,
+// are replaced by a single point with combined fields, placed at position of the first one.
+// Later point's value wins for duplicate field keys. Given points are not modified
+func mergeDuplicatePoints(points []*Point, precision time.Duration) []*Point {
+	merged := make([]*Point, 0, len(points))
+	index := make(map[string]int)
+	for _, point := range points {
+		if point.Time().IsZero() {
+			merged = append(merged, point)
+			continue
+		}
+		key := seriesKey(point) + " " + strconv.FormatInt(point.Time().UnixNano()/int64(precision), 10)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, point)
+			continue
+		}
+		m := *merged[i]
+		fields := make([]*lp.Field, len(m.fields), len(m.fields)+len(point.fields))
+		copy(fields, m.fields)
+		for _, f := range point.fields {
+			if j := fieldIndex(fields, f.Key); j >= 0 {
+				fields[j] = f
+			} else {
+				fields = append(fields, f)
+			}
+		}
+		m.fields = fields
+		merged[i] = &m
+	}
+	return merged
+}
+
+// fieldIndex returns index of the field with key in fields, or -1 if there is no such field
+func fieldIndex(fields []*lp.Field, key string) int {
+	for i, f := range fields {
+		if f.Key == key {
+			return i
+		}
+	}
+	return -1
 }
 
 type writeService struct {
@@ -36,37 +165,71 @@ type writeService struct {
 	lastWriteAttempt time.Time
 	retryQueue       *queue
 	lock             sync.Mutex
+	// nowFunc returns current time, it can be replaced in tests
+	nowFunc func() time.Time
+	// callbacks notified about results of batch writes, guarded by lock
+	successCallback WriteSuccessCallback
+	failedCallback  WriteFailedCallback
+	progress        progressTracker
+	// fieldTypes holds the first observed type of fields by measurement, used according to Options.FieldTypePolicy
+	fieldTypes     map[string]map[string]FieldType
+	fieldTypesLock sync.Mutex
 }
 
 func newWriteService(org string, bucket string, client InfluxDBClient) *writeService {
-	logger.SetDebugLevel(client.Options().LogLevel())
 	retryBufferLimit := client.Options().RetryBufferLimit() / client.Options().BatchSize()
 	if retryBufferLimit == 0 {
 		retryBufferLimit = 1
 	}
-	return &writeService{org: org, bucket: bucket, client: client, retryQueue: newQueue(int(retryBufferLimit))}
+	return &writeService{org: org, bucket: bucket, client: client, retryQueue: newQueue(int(retryBufferLimit)), nowFunc: time.Now}
+}
+
+// setWriteSuccessCallback sets function called with each successfully written batch
+func (w *writeService) setWriteSuccessCallback(callback WriteSuccessCallback) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.successCallback = callback
+}
+
+// setWriteFailedCallback sets function called with each batch, whose write failed
+func (w *writeService) setWriteFailedCallback(callback WriteFailedCallback) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.failedCallback = callback
+}
+
+// callbacks returns actual write result callbacks
+func (w *writeService) callbacks() (WriteSuccessCallback, WriteFailedCallback) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.successCallback, w.failedCallback
+}
+
+// logger returns logger configured in the client options
+func (w *writeService) logger() Logger {
+	return w.client.Options().Logger()
 }
 
 func (w *writeService) handleWrite(ctx context.Context, batch *batch) error {
-	logger.Debug("Write proc: received write request")
+	w.logger().Debugf("Write proc: received write request")
 	batchToWrite := batch
 	retrying := false
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Debug("Write proc: ctx cancelled req")
+			w.logger().Debugf("Write proc: ctx cancelled req")
 			return ctx.Err()
 		default:
 		}
 		if !w.retryQueue.isEmpty() {
-			logger.Debug("Write proc: taking batch from retry queue")
+			w.logger().Debugf("Write proc: taking batch from retry queue")
 			if !retrying {
 				b := w.retryQueue.first()
 				// Can we write? In case of retryable error we must wait a bit
-				if w.lastWriteAttempt.IsZero() || time.Now().After(w.lastWriteAttempt.Add(time.Millisecond*time.Duration(b.retryInterval))) {
+				if w.lastWriteAttempt.IsZero() || w.nowFunc().After(w.lastWriteAttempt.Add(time.Millisecond*time.Duration(b.retryInterval))) {
 					retrying = true
 				} else {
-					logger.Warn("Write proc: cannot write yet, storing batch to queue")
+					w.logger().Warnf("Write proc: cannot write yet, storing batch to queue")
 					w.retryQueue.push(batch)
 					batchToWrite = nil
 				}
@@ -76,7 +239,7 @@ func (w *writeService) handleWrite(ctx context.Context, batch *batch) error {
 				batchToWrite.retries++
 				if batch != nil {
 					if w.retryQueue.push(batch) {
-						logger.Warn("Write proc: Retry buffer full, discarding oldest batch")
+						w.logger().Warnf("Write proc: Retry buffer full, discarding oldest batch")
 					}
 					batch = nil
 				}
@@ -97,63 +260,469 @@ func (w *writeService) handleWrite(ctx context.Context, batch *batch) error {
 
 func (w *writeService) writeBatch(ctx context.Context, batch *batch) error {
 	wUrl, err := w.writeUrl()
+	if err == nil && batch.precision != nil {
+		wUrl, err = urlWithPrecision(wUrl, *batch.precision)
+	}
 	if err != nil {
-		logger.Errorf("%s\n", err.Error())
+		w.logger().Errorf("%s\n", err.Error())
 		return err
 	}
 	var body io.Reader
-	body = strings.NewReader(batch.batch)
-	logger.Debugf("Writing batch: %s", batch.batch)
+	if w.client.Options().OmitTrailingNewline() {
+		body = strings.NewReader(strings.TrimSuffix(batch.batch, "\n"))
+	} else {
+		body = strings.NewReader(batch.batch)
+	}
+	w.logger().Debugf("Writing batch: %s", batch.batch)
 	if w.client.Options().UseGZip() {
 		body, err = gzip.CompressWithGzip(body)
 		if err != nil {
 			return err
 		}
 	}
-	w.lastWriteAttempt = time.Now()
+	w.lastWriteAttempt = w.nowFunc()
 	perror := w.client.postRequest(ctx, wUrl, body, func(req *http.Request) {
 		if w.client.Options().UseGZip() {
 			req.Header.Set("Content-Encoding", "gzip")
 		}
-	}, nil)
+		if w.client.Options().UseIdempotencyKey() {
+			req.Header.Set(idempotencyKeyHeader, idempotencyKey(batch.batch))
+		}
+	}, func(resp *http.Response) error {
+		batch.statusCode = resp.StatusCode
+		return resp.Body.Close()
+	})
+	successCallback, failedCallback := w.callbacks()
 	if perror != nil {
-		if perror.StatusCode == http.StatusTooManyRequests || perror.StatusCode == http.StatusServiceUnavailable {
-			logger.Errorf("Write error: %s\nBatch kept for retrying\n", perror.Error())
+		retryable := w.isRetryable(perror)
+		if !retryable && (perror.StatusCode == http.StatusUnauthorized || perror.StatusCode == http.StatusForbidden) {
+			perror.Message = w.unauthorizedMessage(perror.Message)
+		}
+		if failedCallback != nil && !failedCallback(batch.batch, perror, batch.retries) {
+			retryable = false
+		}
+		if batch.direct {
+			retryable = false
+		}
+		if retryable {
+			w.logger().Errorf("Write error: %s\nBatch kept for retrying\n", perror.Error())
 			if perror.RetryAfter > 0 {
 				batch.retryInterval = perror.RetryAfter * 1000
 			} else {
-				batch.retryInterval = w.client.Options().RetryInterval()
+				batch.retryInterval = w.retryInterval(batch.retries)
 			}
 			if batch.retries < w.client.Options().MaxRetries() {
 				if w.retryQueue.push(batch) {
-					logger.Warn("Retry buffer full, discarding oldest batch")
+					w.logger().Warnf("Retry buffer full, discarding oldest batch")
 				}
+			} else {
+				batch.resolve(perror)
 			}
 		} else {
-			logger.Errorf("Write error: %s\n", perror.Error())
+			w.logger().Errorf("Write error: %s\n", perror.Error())
+			batch.resolve(perror)
 		}
 		return perror
 	} else {
-		w.lastWriteAttempt = time.Now()
+		w.lastWriteAttempt = w.nowFunc()
+	}
+	if successCallback != nil {
+		successCallback(batch.batch)
+	}
+	w.progress.add(batch.batch)
+	batch.resolve(nil)
+	return nil
+}
+
+// retryInterval returns interval, in ms, before retrying a batch, which was already retried retries times.
+// RetryInterval is doubled with each retry, up to MaxRetryInterval, and randomly shortened by up to a half as a jitter
+func (w *writeService) retryInterval(retries uint) uint {
+	interval := w.client.Options().RetryInterval()
+	maxInterval := w.client.Options().MaxRetryInterval()
+	for i := uint(0); i < retries && (maxInterval == 0 || interval < maxInterval); i++ {
+		interval *= 2
+	}
+	if maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+	if interval < 2 {
+		return interval
+	}
+	return interval - uint(rand.Int63n(int64(interval/2)+1))
+}
+
+// isRetryable returns true if write failed with status code configured by RetryableStatusCodes
+func (w *writeService) isRetryable(perror *Error) bool {
+	for _, code := range w.client.Options().RetryableStatusCodes() {
+		if perror.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorizedMessage returns message of unauthorized write error identifying target org and bucket
+func (w *writeService) unauthorizedMessage(message string) string {
+	m := fmt.Sprintf("token not authorized for bucket %s in org %s", w.bucket, w.org)
+	if message != "" {
+		m += ": " + message
+	}
+	return m
+}
+
+// idempotencyKey returns key identifying content of a batch, which is the same for all retries of the batch
+func idempotencyKey(batch string) string {
+	sum := sha256.Sum256([]byte(batch))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeGzipped sends already gzip compressed line protocol to the server
+func (w *writeService) writeGzipped(ctx context.Context, body io.Reader) error {
+	wUrl, err := w.writeUrl()
+	if err != nil {
+		w.logger().Errorf("%s\n", err.Error())
+		return err
+	}
+	perror := w.client.postRequest(ctx, wUrl, body, func(req *http.Request) {
+		req.Header.Set("Content-Encoding", "gzip")
+	}, func(resp *http.Response) error {
+		return resp.Body.Close()
+	})
+	if perror != nil {
+		w.logger().Errorf("Write error: %s\n", perror.Error())
+		return perror
+	}
+	return nil
+}
+
+// ErrEmptyMeasurement is returned when encoding a point with empty measurement name
+var ErrEmptyMeasurement = errors.New("point has empty measurement name")
+
+// ErrNoFields is returned when encoding a point without fields
+var ErrNoFields = errors.New("point has no fields")
+
+// ErrTooManyFields is returned when encoding a point with more fields than Options.MaxFieldsPerPoint
+var ErrTooManyFields = errors.New("point has too many fields")
+
+// ErrTimestampOutOfRange is returned when encoding a point with timestamp not representable as int64 nanoseconds
+// since the epoch, i.e. before 1677-09-21T00:12:43.145224192Z or after 2262-04-11T23:47:16.854775807Z
+var ErrTimestampOutOfRange = errors.New("point timestamp out of range")
+
+var (
+	// minTimestamp and maxTimestamp limit timestamps representable in nanoseconds
+	minTimestamp = time.Unix(0, math.MinInt64)
+	maxTimestamp = time.Unix(0, math.MaxInt64)
+)
+
+// timestampInRange returns true if t is representable in nanoseconds
+func timestampInRange(t time.Time) bool {
+	return !t.Before(minTimestamp) && !t.After(maxTimestamp)
+}
+
+// validatePoint returns error if point cannot be encoded into a valid line protocol record
+// or has more than maxFields fields. Zero maxFields means no limit
+func validatePoint(point *Point, maxFields uint) error {
+	if point.Name() == "" {
+		return ErrEmptyMeasurement
+	}
+	if len(point.FieldList()) == 0 {
+		return fmt.Errorf("point %s: %w", point.Name(), ErrNoFields)
+	}
+	if maxFields > 0 && uint(len(point.FieldList())) > maxFields {
+		return fmt.Errorf("point %s: %w, %d fields exceed maximum of %d", point.Name(), ErrTooManyFields, len(point.FieldList()), maxFields)
+	}
+	if t := point.Time(); !t.IsZero() && !timestampInRange(t) {
+		return fmt.Errorf("point %s: %w, %s is not between %s and %s", point.Name(), ErrTimestampOutOfRange,
+			t.Format(time.RFC3339), minTimestamp.UTC().Format(time.RFC3339), maxTimestamp.UTC().Format(time.RFC3339))
 	}
 	return nil
 }
 
 func (w *writeService) encodePoints(points ...*Point) (string, error) {
-	var buffer bytes.Buffer
-	e := lp.NewEncoder(&buffer)
+	return w.encodePointsWithPrecision(w.client.Options().WritePrecision(), points...)
+}
+
+// encodePointsWithPrecision encodes points into line protocol with timestamps in writePrecision
+func (w *writeService) encodePointsWithPrecision(writePrecision Precision, points ...*Point) (string, error) {
+	pe := encoderPool.Get().(*pointEncoder)
+	defer pe.release()
+	buffer, e := &pe.buffer, pe.encoder
 	e.SetFieldTypeSupport(lp.UintSupport)
 	e.FailOnFieldErr(true)
-	e.SetPrecision(w.client.Options().Precision())
+	precision := writePrecision.Duration()
+	e.SetPrecision(precision)
+	if w.client.Options().MergeDuplicatePoints() && len(points) > 1 {
+		points = mergeDuplicatePoints(points, precision)
+	}
 	for _, point := range points {
+		if w.client.Options().WarnOnPrecisionLoss() && !point.Time().IsZero() && timestampInRange(point.Time()) && point.Time().UnixNano()%int64(precision) != 0 {
+			w.logger().Warnf("Timestamp %s of point %s is truncated to precision %s\n", point.Time().Format(time.RFC3339Nano), point.Name(), writePrecision)
+		}
+		if w.client.Options().CoerceFieldsToFloat() {
+			point = floatFields(point)
+		}
+		if defaultTags := w.client.Options().DefaultTags(); len(defaultTags) > 0 {
+			point = withDefaultTags(point, defaultTags)
+		}
+		if policy := w.client.Options().NonFiniteFloatPolicy(); policy != NonFiniteFloatError {
+			point = w.finiteFields(point, policy)
+			if point == nil {
+				continue
+			}
+		}
+		if err := validatePoint(point, w.client.Options().MaxFieldsPerPoint()); err != nil {
+			return "", err
+		}
+		if policy := w.client.Options().FieldTypePolicy(); policy != FieldTypePolicyIgnore {
+			var err error
+			if point, err = w.registeredFieldTypes(point, policy); err != nil {
+				return "", err
+			}
+		}
 		_, err := e.Encode(point)
 		if err != nil {
+			var fieldErr *lp.FieldError
+			if errors.As(err, &fieldErr) {
+				return "", pointFieldError(point, err)
+			}
 			return "", err
 		}
 	}
 	return buffer.String(), nil
 }
 
+// maxPooledEncoderSize is maximum capacity of the buffer of pointEncoder returned into encoderPool,
+// so that encoding of a huge batch doesn't hold its memory
+const maxPooledEncoderSize = 1 << 20
+
+// pointEncoder is line protocol encoder with its output buffer, reused through encoderPool to reduce allocations
+type pointEncoder struct {
+	buffer  bytes.Buffer
+	encoder *lp.Encoder
+}
+
+// encoderPool holds *pointEncoder instances
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		pe := &pointEncoder{}
+		pe.encoder = lp.NewEncoder(&pe.buffer)
+		return pe
+	},
+}
+
+// release clears the buffer and returns the encoder into encoderPool
+func (pe *pointEncoder) release() {
+	if pe.buffer.Cap() > maxPooledEncoderSize {
+		return
+	}
+	pe.buffer.Reset()
+	encoderPool.Put(pe)
+}
+
+// FieldEncodingError is error of encoding a field of a point into line protocol
+type FieldEncodingError struct {
+	// Measurement is name of the point
+	Measurement string
+	// Key is key of the field
+	Key string
+	// Value is value of the field
+	Value interface{}
+	// Err is the encoding error
+	Err error
+}
+
+// Error fulfils error interface
+func (e *FieldEncodingError) Error() string {
+	return fmt.Sprintf("point %s, field %s=%v: %s", e.Measurement, e.Key, e.Value, e.Err.Error())
+}
+
+// Unwrap returns the encoding error
+func (e *FieldEncodingError) Unwrap() error {
+	return e.Err
+}
+
+// pointFieldError returns FieldEncodingError of the first field of point, which fails to encode, or err if no such field is found
+func pointFieldError(point *Point, err error) error {
+	e := lp.NewEncoder(ioutil.Discard)
+	e.SetFieldTypeSupport(lp.UintSupport)
+	e.FailOnFieldErr(true)
+	for _, f := range point.fields {
+		single := *point
+		single.fields = []*lp.Field{f}
+		if _, ferr := e.Encode(&single); ferr != nil {
+			return &FieldEncodingError{Measurement: point.Name(), Key: f.Key, Value: f.Value, Err: ferr}
+		}
+	}
+	return err
+}
+
+// finiteFields returns point without fields with NaN or infinite values, or nil if point is dropped according to policy.
+// Given point is not modified
+func (w *writeService) finiteFields(point *Point, policy NonFiniteFloatPolicy) *Point {
+	var fields []*lp.Field
+	for i, f := range point.fields {
+		if v, ok := f.Value.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			if policy == NonFiniteFloatDropPoint {
+				w.logger().Warnf("Point %s dropped, field %s has non-finite value %v\n", point.Name(), f.Key, v)
+				return nil
+			}
+			w.logger().Warnf("Field %s of point %s skipped, it has non-finite value %v\n", f.Key, point.Name(), v)
+			if fields == nil {
+				fields = make([]*lp.Field, i, len(point.fields))
+				copy(fields, point.fields[:i])
+			}
+			continue
+		}
+		if fields != nil {
+			fields = append(fields, f)
+		}
+	}
+	if fields == nil {
+		return point
+	}
+	if len(fields) == 0 {
+		w.logger().Warnf("Point %s dropped, it has no finite field\n", point.Name())
+		return nil
+	}
+	finite := *point
+	finite.fields = fields
+	return &finite
+}
+
+// ErrFieldTypeConflict is returned when encoding a point with a field value conflicting with the first observed type of the field
+var ErrFieldTypeConflict = errors.New("field type conflict")
+
+// registeredFieldTypes returns point with fields of the types observed first in the measurement, registering types of new fields.
+// Conflicting values are converted or rejected according to policy. Given point is not modified
+func (w *writeService) registeredFieldTypes(point *Point, policy FieldTypePolicy) (*Point, error) {
+	w.fieldTypesLock.Lock()
+	defer w.fieldTypesLock.Unlock()
+	if w.fieldTypes == nil {
+		w.fieldTypes = make(map[string]map[string]FieldType)
+	}
+	types, ok := w.fieldTypes[point.Name()]
+	if !ok {
+		types = make(map[string]FieldType)
+		w.fieldTypes[point.Name()] = types
+	}
+	var fields []*lp.Field
+	for i, f := range point.fields {
+		valueType := fieldType(f.Value)
+		registered, ok := types[f.Key]
+		if !ok || registered == valueType {
+			continue
+		}
+		value, converted := convertFieldType(f.Value, registered)
+		if policy == FieldTypePolicyReject || !converted {
+			return nil, fmt.Errorf("point %s: %w, field %s value %v is %s, but the field is %s", point.Name(), ErrFieldTypeConflict, f.Key, f.Value, valueType, registered)
+		}
+		if fields == nil {
+			fields = make([]*lp.Field, len(point.fields))
+			copy(fields, point.fields)
+		}
+		fields[i] = &lp.Field{Key: f.Key, Value: value}
+	}
+	// register types only of valid points
+	for _, f := range point.fields {
+		if _, ok := types[f.Key]; !ok {
+			types[f.Key] = fieldType(f.Value)
+		}
+	}
+	if fields == nil {
+		return point, nil
+	}
+	coerced := *point
+	coerced.fields = fields
+	return &coerced, nil
+}
+
+// fieldType returns line protocol type of the field value
+func fieldType(value interface{}) FieldType {
+	switch value.(type) {
+	case float64:
+		return FieldTypeFloat
+	case int64:
+		return FieldTypeInteger
+	case uint64:
+		return FieldTypeUnsigned
+	case bool:
+		return FieldTypeBool
+	default:
+		return FieldTypeString
+	}
+}
+
+// convertFieldType converts numeric value to the field type without loss of precision.
+// Returns false if value cannot be converted
+func convertFieldType(value interface{}, toType FieldType) (interface{}, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, false
+		}
+		switch {
+		case toType == FieldTypeInteger && v >= math.MinInt64 && v < math.MaxInt64:
+			return int64(v), true
+		case toType == FieldTypeUnsigned && v >= 0 && v < math.MaxUint64:
+			return uint64(v), true
+		}
+	case int64:
+		switch {
+		case toType == FieldTypeFloat && v == int64(float64(v)):
+			return float64(v), true
+		case toType == FieldTypeUnsigned && v >= 0:
+			return uint64(v), true
+		}
+	case uint64:
+		switch {
+		case toType == FieldTypeFloat && v == uint64(float64(v)):
+			return float64(v), true
+		case toType == FieldTypeInteger && v <= math.MaxInt64:
+			return int64(v), true
+		}
+	}
+	return nil, false
+}
+
+// floatFields returns copy of point with integer fields converted to float. Given point is not modified
+func floatFields(point *Point) *Point {
+	coerced := *point
+	coerced.fields = make([]*lp.Field, len(point.fields))
+	for i, f := range point.fields {
+		switch v := f.Value.(type) {
+		case int64:
+			coerced.fields[i] = &lp.Field{Key: f.Key, Value: float64(v)}
+		case uint64:
+			coerced.fields[i] = &lp.Field{Key: f.Key, Value: float64(v)}
+		default:
+			coerced.fields[i] = f
+		}
+	}
+	return &coerced
+}
+
+// checkLineLength returns error if line protocol record is longer than MaxLineBytes
+func (w *writeService) checkLineLength(line string) error {
+	maxLineBytes := w.client.Options().MaxLineBytes()
+	length := len(strings.TrimSuffix(line, "\n"))
+	if maxLineBytes > 0 && uint(length) > maxLineBytes {
+		return fmt.Errorf("line protocol record of %d bytes exceeds maximum length %d bytes", length, maxLineBytes)
+	}
+	return nil
+}
+
+// urlWithPrecision returns write url with precision param set to precision
+func urlWithPrecision(writeUrl string, precision Precision) (string, error) {
+	u, err := url.Parse(writeUrl)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	params.Set("precision", precision.String())
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
 func (w *writeService) writeUrl() (string, error) {
 	if w.url == "" {
 		u, err := url.Parse(w.client.ServerUrl())
@@ -165,7 +734,7 @@ func (w *writeService) writeUrl() (string, error) {
 		params := u.Query()
 		params.Set("org", w.org)
 		params.Set("bucket", w.bucket)
-		params.Set("precision", precisionToString(w.client.Options().Precision()))
+		params.Set("precision", w.client.Options().WritePrecision().String())
 		u.RawQuery = params.Encode()
 		w.lock.Lock()
 		w.url = u.String()
@@ -173,16 +742,3 @@ func (w *writeService) writeUrl() (string, error) {
 	}
 	return w.url, nil
 }
-
-func precisionToString(precision time.Duration) string {
-	prec := "ns"
-	switch precision {
-	case time.Microsecond:
-		prec = "us"
-	case time.Millisecond:
-		prec = "ms"
-	case time.Second:
-		prec = "s"
-	}
-	return prec
-}
@@ -5,18 +5,28 @@
 package influxdb2
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/bonitoo-io/influxdb-client-go/domain"
+	lp "github.com/influxdata/line-protocol"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
 	"io/ioutil"
+	"log"
+	"math"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -39,7 +49,11 @@ func (t *testClient) WriteApi(string, string) WriteApi {
 	return nil
 }
 
-func (t *testClient) Close() {
+func (t *testClient) WriteApiWithContext(context.Context, string, string) WriteApi {
+	return nil
+}
+
+func (t *testClient) Close() error {
 	t.lock.Lock()
 	if len(t.lines) > 0 {
 		t.lines = t.lines[:0]
@@ -48,18 +62,50 @@ func (t *testClient) Close() {
 	t.replyError = nil
 	t.requestHandler = nil
 	t.lock.Unlock()
+	return nil
 }
 
 func (t *testClient) QueryApi(string) QueryApi {
 	return nil
 }
 
+func (t *testClient) DeleteApi(string, string) DeleteApi {
+	return nil
+}
+
+func (t *testClient) WriteApis() []WriteApi {
+	return nil
+}
+
+func (t *testClient) BucketsApi() BucketsApi {
+	return nil
+}
+
+func (t *testClient) TasksApi() TasksApi {
+	return nil
+}
+
+func (t *testClient) DefaultWriteApi() WriteApi {
+	return nil
+}
+
+func (t *testClient) DefaultQueryApi() QueryApi {
+	return nil
+}
+
 func (t *testClient) ReplyError() *Error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 	return t.replyError
 }
 
+// SetReplyError sets error returned by following write requests, nil means successful writes
+func (t *testClient) SetReplyError(replyError *Error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.replyError = replyError
+}
+
 func (t *testClient) postRequest(_ context.Context, url string, body io.Reader, requestCallback RequestCallback, _ ResponseCallback) *Error {
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
@@ -90,6 +136,18 @@ func (t *testClient) postRequest(_ context.Context, url string, body io.Reader,
 	}
 }
 
+func (t *testClient) getRequest(context.Context, string, RequestCallback, ResponseCallback) *Error {
+	return nil
+}
+
+func (t *testClient) patchRequest(context.Context, string, io.Reader, RequestCallback, ResponseCallback) *Error {
+	return nil
+}
+
+func (t *testClient) deleteRequest(context.Context, string, RequestCallback, ResponseCallback) *Error {
+	return nil
+}
+
 func (t *testClient) decodeLines(body io.Reader) error {
 	bytes, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -124,6 +182,18 @@ func (t *testClient) Ready(context.Context) (bool, error) {
 	return true, nil
 }
 
+func (t *testClient) ServerCapabilities(context.Context) (*Capabilities, error) {
+	return nil, nil
+}
+
+func (t *testClient) Health(context.Context) (*domain.HealthCheck, error) {
+	return nil, nil
+}
+
+func (t *testClient) Connect(context.Context) error {
+	return nil
+}
+
 func genPoints(num int) []*Point {
 	points := make([]*Point, num)
 	rand.Seed(321)
@@ -243,6 +313,24 @@ func TestFlushInterval(t *testing.T) {
 	writeApi.Close()
 }
 
+// fakeClock is a manually advanced clock replacing time.Now in tests
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
 func TestRetry(t *testing.T) {
 	client := &testClient{
 		options: DefaultOptions(),
@@ -252,6 +340,8 @@ func TestRetry(t *testing.T) {
 		SetBatchSize(5).
 		SetRetryInterval(10000)
 	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	clock := &fakeClock{now: time.Now()}
+	writeApi.service.nowFunc = clock.Now
 	points := genPoints(15)
 	for i := 0; i < 5; i++ {
 		writeApi.WritePoint(points[i])
@@ -259,10 +349,10 @@ func TestRetry(t *testing.T) {
 	writeApi.waitForFlushing()
 	require.Len(t, client.Lines(), 5)
 	client.Close()
-	client.replyError = &Error{
+	client.SetReplyError(&Error{
 		StatusCode: 429,
 		RetryAfter: 5,
-	}
+	})
 	for i := 0; i < 5; i++ {
 		writeApi.WritePoint(points[i])
 	}
@@ -274,28 +364,70 @@ func TestRetry(t *testing.T) {
 	}
 	writeApi.waitForFlushing()
 	require.Len(t, client.Lines(), 0)
-	time.Sleep(5*time.Second + 50*time.Millisecond)
+	clock.Add(4 * time.Second)
+	writeApi.WriteRecord("test,hostname=host_x f=1i 1")
+	writeApi.Flush()
+	// still waiting for retry
+	require.Len(t, client.Lines(), 0)
+	clock.Add(time.Second + 50*time.Millisecond)
 	for i := 10; i < 15; i++ {
 		writeApi.WritePoint(points[i])
 	}
 	writeApi.waitForFlushing()
-	require.Len(t, client.Lines(), 15)
+	require.Len(t, client.Lines(), 16)
 	assert.True(t, strings.HasPrefix(client.Lines()[7], "test,hostname=host_7"))
-	assert.True(t, strings.HasPrefix(client.Lines()[14], "test,hostname=host_14"))
+	assert.True(t, strings.HasPrefix(client.Lines()[10], "test,hostname=host_x"))
+	assert.True(t, strings.HasPrefix(client.Lines()[15], "test,hostname=host_14"))
 	writeApi.Close()
 }
 
+func TestCloseUnderLoad(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(50).SetFlushInterval(1)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	const writers, count = 20, 500
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				if j%2 == 0 {
+					writeApi.WritePoint(NewPoint("test", map[string]string{"w": strconv.Itoa(i)}, map[string]interface{}{"j": j}, time.Unix(0, int64(j))))
+				} else {
+					writeApi.WriteRecord(fmt.Sprintf("test,w=%d j=%di %d", i, j, j))
+				}
+				if j%100 == 0 {
+					writeApi.Flush()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	writeApi.Close()
+	lines := client.Lines()
+	require.Len(t, lines, writers*count)
+	unique := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		unique[line] = true
+	}
+	assert.Len(t, unique, writers*count)
+}
+
 func TestWriteError(t *testing.T) {
 	client := &testClient{
 		options: DefaultOptions(),
 		t:       t,
 	}
 	client.options.SetLogLevel(3).SetBatchSize(5)
-	client.replyError = &Error{
+	client.SetReplyError(&Error{
 		StatusCode: 400,
 		Code:       "write",
 		Message:    "error",
-	}
+	})
 	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
 	errCh := writeApi.Errors()
 	var recErr error
@@ -315,3 +447,814 @@ func TestWriteError(t *testing.T) {
 
 	client.Close()
 }
+
+func TestWriteAdjustDuplicateTimestamps(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(3).SetAdjustDuplicateTimestamps(true)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	ts := time.Unix(0, 1000)
+	for i := 0; i < 6; i++ {
+		writeApi.WritePoint(NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"v": i}, ts))
+	}
+	writeApi.Close()
+	// timestamps are unique across batches
+	expected := []string{
+		"test,id=a v=0i 1000",
+		"test,id=a v=1i 1001",
+		"test,id=a v=2i 1002",
+		"test,id=a v=3i 1003",
+		"test,id=a v=4i 1004",
+		"test,id=a v=5i 1005",
+	}
+	assert.Equal(t, expected, client.Lines())
+}
+
+func TestSeriesTimestampsAdjust(t *testing.T) {
+	timestamps := make(seriesTimestamps)
+	point := func(ts int64) *Point {
+		return NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"v": 1}, time.Unix(0, ts))
+	}
+	var adjusted []int64
+	for _, ts := range []int64{1000, 1000, 1001, 500, 1000, 2000, 2000} {
+		adjusted = append(adjusted, timestamps.adjust(point(ts), time.Nanosecond).Time().UnixNano())
+	}
+	// out of order point is not adjusted
+	assert.Equal(t, []int64{1000, 1001, 1002, 500, 1003, 2000, 2001}, adjusted)
+
+	for i := 0; i < maxTimestampSeries+10; i++ {
+		timestamps.adjust(NewPoint("test", map[string]string{"id": strconv.Itoa(i)}, map[string]interface{}{"v": 1}, time.Unix(0, 1000)), time.Nanosecond)
+	}
+	assert.Len(t, timestamps, maxTimestampSeries)
+}
+
+func TestMergeDuplicatePoints(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	service := newWriteService("my-org", "my-bucket", client)
+	ts := time.Unix(0, 1000)
+	first := NewPoint("test", map[string]string{"id": "a", "dc": "x"}, map[string]interface{}{"a": 1, "b": 1}, ts)
+	points := []*Point{
+		first,
+		NewPoint("test", map[string]string{"id": "b"}, map[string]interface{}{"a": 1}, ts),
+		NewPoint("test", map[string]string{"dc": "x", "id": "a"}, map[string]interface{}{"b": 2, "c": 3}, ts),
+		NewPoint("test", map[string]string{"id": "a", "dc": "x"}, map[string]interface{}{"a": 4}, ts.Add(time.Microsecond)),
+	}
+
+	line, err := service.encodePoints(points...)
+	require.Nil(t, err)
+	assert.Equal(t, 4, strings.Count(line, "\n"))
+
+	client.options.SetMergeDuplicatePoints(true)
+	line, err = service.encodePoints(points...)
+	require.Nil(t, err)
+	expected := "test,dc=x,id=a a=1i,b=2i,c=3i 1000\n" +
+		"test,id=b a=1i 1000\n" +
+		"test,dc=x,id=a a=4i 2000\n"
+	assert.Equal(t, expected, line)
+	// original point is untouched
+	assert.Len(t, first.FieldList(), 2)
+
+	// timestamps equal in units of precision are duplicates
+	client.options.SetPrecision(time.Millisecond)
+	line, err = service.encodePoints(points...)
+	require.Nil(t, err)
+	assert.Equal(t, "test,dc=x,id=a a=4i,b=2i,c=3i 0\ntest,id=b a=1i 0\n", line)
+}
+
+func TestFieldEncodingError(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	service := newWriteService("my-org", "my-bucket", client)
+	valid := NewPoint("test", nil, map[string]interface{}{"a": 1.5}, time.Unix(0, 1000))
+	p := NewPoint("cpu", map[string]string{"id": "a"}, map[string]interface{}{"a": 1.0, "b": math.NaN()}, time.Unix(0, 1000))
+
+	_, err := service.encodePoints(valid, p)
+	require.NotNil(t, err)
+	assert.Equal(t, "point cpu, field b=NaN: is NaN", err.Error())
+	var fieldErr *FieldEncodingError
+	require.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "cpu", fieldErr.Measurement)
+	assert.Equal(t, "b", fieldErr.Key)
+	assert.True(t, math.IsNaN(fieldErr.Value.(float64)))
+	assert.Equal(t, lp.ErrIsNaN, errors.Unwrap(err))
+
+	p = NewPointWithMeasurement("mem").AddField("free", 1).AddField("", "x")
+	_, err = service.encodePoints(p)
+	require.NotNil(t, err)
+	assert.Equal(t, "point mem, field =x: invalid field key", err.Error())
+}
+
+func TestNonFiniteFloatPolicy(t *testing.T) {
+	logger := &testLogger{}
+	client := &testClient{
+		options: DefaultOptions().SetLogger(logger),
+		t:       t,
+	}
+	service := newWriteService("my-org", "my-bucket", client)
+	ts := time.Unix(0, 1000)
+	valid := NewPoint("test", nil, map[string]interface{}{"a": 1.5}, ts)
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		logger.messages = nil
+		p := NewPoint("test", map[string]string{"id": "a"}, map[string]interface{}{"a": 1.0, "b": v, "c": 2}, ts)
+		onlyNonFinite := NewPoint("test", map[string]string{"id": "b"}, map[string]interface{}{"b": float32(v)}, ts)
+
+		client.options.SetNonFiniteFloatPolicy(NonFiniteFloatError)
+		_, err := service.encodePoints(valid, p)
+		assert.NotNil(t, err)
+
+		client.options.SetNonFiniteFloatPolicy(NonFiniteFloatSkipField)
+		line, err := service.encodePoints(valid, p, onlyNonFinite)
+		require.Nil(t, err)
+		assert.Equal(t, "test a=1.5 1000\ntest,id=a a=1,c=2i 1000\n", line)
+		// original point is untouched
+		assert.Len(t, p.FieldList(), 3)
+		assert.Len(t, logger.messages, 3)
+		assert.Equal(t, "W Point test dropped, it has no finite field\n", logger.messages[2])
+
+		client.options.SetNonFiniteFloatPolicy(NonFiniteFloatDropPoint)
+		line, err = service.encodePoints(valid, p, onlyNonFinite)
+		require.Nil(t, err)
+		assert.Equal(t, "test a=1.5 1000\n", line)
+		assert.Len(t, logger.messages, 5)
+		assert.Equal(t, fmt.Sprintf("W Point test dropped, field b has non-finite value %v\n", v), logger.messages[3])
+	}
+}
+
+func TestWriteMultiLineRecord(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(3).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	lines := genRecords(3)
+	writeApi.WriteRecord(strings.Join(lines, "\n") + "\n")
+	// batch size is reached by single call, so batch is sent without waiting for flush interval
+	writeApi.waitForFlushing()
+	time.Sleep(time.Millisecond * 10)
+	require.Len(t, client.Lines(), 3)
+	assert.Equal(t, lines, client.Lines())
+	writeApi.Close()
+}
+
+func TestMaxBufferedBytes(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	// each record takes 11 bytes including new line
+	record := "a,t=x f=1i"
+	client.options.SetBatchSize(100).SetFlushInterval(10000).SetMaxBufferedBytes(30).SetDropOnBufferFull(true)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	for i := 0; i < 5; i++ {
+		writeApi.WriteRecord(record)
+	}
+	assert.Equal(t, WriteStats{BufferedBytes: 33, DroppedRecords: 2}, writeApi.Stats())
+	writeApi.Close()
+	assert.Len(t, client.Lines(), 3)
+	assert.Equal(t, WriteStats{BufferedBytes: 0, DroppedRecords: 2}, writeApi.Stats())
+
+	client.Close()
+	client.options.SetDropOnBufferFull(false)
+	writeApi = newWriteApiImpl("my-org", "my-bucket", client)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	finished := false
+	go func() {
+		for i := 0; i < 5; i++ {
+			writeApi.WriteRecord(record)
+		}
+		finished = true
+		wg.Done()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// writing is blocked until buffer is flushed
+	assert.False(t, finished)
+	assert.Equal(t, uint(33), writeApi.Stats().BufferedBytes)
+	assert.Len(t, client.Lines(), 0)
+	writeApi.Flush()
+	wg.Wait()
+	writeApi.Close()
+	assert.Len(t, client.Lines(), 5)
+	assert.Equal(t, WriteStats{BufferedBytes: 0, DroppedRecords: 0}, writeApi.Stats())
+}
+
+func TestPrecisionLossWarning(t *testing.T) {
+	var logOutput bytes.Buffer
+	log.SetOutput(&logOutput)
+	defer log.SetOutput(os.Stderr)
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetLogLevel(1).SetPrecision(time.Second)
+	service := newWriteService("my-org", "my-bucket", client)
+	p := NewPoint("test", nil, map[string]interface{}{"f": 1}, time.Unix(60, 70))
+
+	line, err := service.encodePoints(p)
+	require.Nil(t, err)
+	assert.Equal(t, "test f=1i 60\n", line)
+	assert.Equal(t, "", logOutput.String())
+
+	client.options.SetWarnOnPrecisionLoss(true)
+	line, err = service.encodePoints(p, NewPoint("test", nil, map[string]interface{}{"f": 1}, time.Unix(60, 0)))
+	require.Nil(t, err)
+	assert.Equal(t, "test f=1i 60\ntest f=1i 60\n", line)
+	assert.Contains(t, logOutput.String(), "[W]! Timestamp 1970-01-01T00:01:00.00000007Z of point test is truncated to precision s")
+	assert.Equal(t, 1, strings.Count(logOutput.String(), "[W]!"))
+}
+
+func TestWritePointNow(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(100).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	points := genPoints(3)
+	writeApi.WritePoint(points[0])
+	err := writeApi.WritePointNow(context.Background(), points[1])
+	require.Nil(t, err)
+	// only urgent point is sent, buffered point waits for flush
+	require.Len(t, client.Lines(), 1)
+	line := points[1].ToLineProtocol(client.options.Precision())
+	assert.Equal(t, line[:len(line)-1], client.Lines()[0])
+
+	client.SetReplyError(&Error{Code: "invalid", Message: "data"})
+	err = writeApi.WritePointNow(context.Background(), points[2])
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid: data", err.Error())
+	client.SetReplyError(nil)
+
+	writeApi.Close()
+	require.Len(t, client.Lines(), 2)
+}
+
+func TestWritePointNowWithRetryQueue(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(1).SetRetryInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	errCh := writeApi.Errors()
+	points := genPoints(3)
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "down"})
+	require.Nil(t, writeApi.WritePoint(points[0]))
+	<-errCh
+	queued := writeApi.Stats().BufferedBytes
+	require.True(t, queued > 0)
+
+	// point is written right away, while the failed batch waits for retry
+	client.SetReplyError(nil)
+	require.Nil(t, writeApi.WritePointNow(context.Background(), points[1]))
+	line := points[1].ToLineProtocol(client.options.Precision())
+	assert.Equal(t, []string{line[:len(line)-1]}, client.Lines())
+	assert.Equal(t, queued, writeApi.Stats().BufferedBytes)
+
+	// failed point is reported and not kept for retrying
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "down"})
+	err := writeApi.WritePointNow(context.Background(), points[2])
+	require.NotNil(t, err)
+	assert.Equal(t, "unavailable: down", err.Error())
+	assert.Equal(t, queued, writeApi.Stats().BufferedBytes)
+	client.SetReplyError(nil)
+	go func() {
+		for range errCh {
+		}
+	}()
+	writeApi.Close()
+}
+
+func TestWriteBatchAt(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(10).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	ts := time.Unix(60, 0)
+	points := []*Point{
+		NewPointWithMeasurement("test").AddField("a", 1),
+		NewPointWithMeasurement("test").AddField("a", 2),
+		NewPoint("test", nil, map[string]interface{}{"a": 3}, time.Unix(0, 1000)),
+	}
+	err := writeApi.WriteBatchAt(ts, points...)
+	require.Nil(t, err)
+	writeApi.Close()
+	assert.Equal(t, []string{"test a=1i 60000000000", "test a=2i 60000000000", "test a=3i 1000"}, client.Lines())
+	// given points are not modified
+	assert.True(t, points[0].Time().IsZero())
+
+	err = writeApi.WriteBatchAt(ts, points...)
+	assert.Equal(t, ErrWriteApiClosed, err)
+}
+
+func TestWriteInvalidPointsAsync(t *testing.T) {
+	logger := &testLogger{}
+	client := &testClient{
+		options: DefaultOptions().SetLogger(logger),
+		t:       t,
+	}
+	client.options.SetBatchSize(10).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	writeApi.WritePoint(NewPointWithMeasurement("").AddField("a", 1))
+	writeApi.WritePoint(NewPointWithMeasurement("test").AddTag("id", "a"))
+	writeApi.WritePoint(NewPointWithMeasurement("test").AddField("a", 1))
+	writeApi.Close()
+	// invalid points are dropped, valid point is written
+	assert.Equal(t, []string{"test a=1i"}, client.Lines())
+	assert.Contains(t, logger.messages, "W Point dropped: point has empty measurement name\n")
+	assert.Contains(t, logger.messages, "W Point dropped: point test: point has no fields\n")
+}
+
+func TestWriteScanner(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(2).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	input := "test a=1i 1\ntest a=2i 2\n\ntest a=3i 3\ntest a=4i 4\ntest a=5i 5\n"
+	var batches []int
+	err := writeApi.WriteScanner(context.Background(), bufio.NewScanner(strings.NewReader(input)), func(n int) {
+		batches = append(batches, n)
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []int{2, 2, 1}, batches)
+	assert.Equal(t, []string{"test a=1i 1", "test a=2i 2", "test a=3i 3", "test a=4i 4", "test a=5i 5"}, client.Lines())
+
+	// records read before scanner error are written
+	client.lines = nil
+	batches = nil
+	s := bufio.NewScanner(strings.NewReader("test a=1i 1\ntest a=2i 2\ntest a=3i 3\ntest,too=long a=4i 4\n"))
+	s.Buffer(make([]byte, 16), 16)
+	err = writeApi.WriteScanner(context.Background(), s, func(n int) {
+		batches = append(batches, n)
+	})
+	assert.Equal(t, bufio.ErrTooLong, err)
+	assert.Equal(t, []int{2, 1}, batches)
+	assert.Len(t, client.Lines(), 3)
+
+	// write error stops reading
+	client.lines = nil
+	batches = nil
+	client.SetReplyError(&Error{Code: "invalid", Message: "data"})
+	err = writeApi.WriteScanner(context.Background(), bufio.NewScanner(strings.NewReader(input)), func(n int) {
+		batches = append(batches, n)
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid: data", err.Error())
+	assert.Len(t, batches, 0)
+	client.SetReplyError(nil)
+
+	writeApi.Close()
+	err = writeApi.WriteScanner(context.Background(), bufio.NewScanner(strings.NewReader(input)), nil)
+	assert.Equal(t, ErrWriteApiClosed, err)
+}
+
+func TestWriteScannerRetryableError(t *testing.T) {
+	var lock sync.Mutex
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if lines == nil {
+			lines = []string{}
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	written := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(lines)
+	}
+	c := NewClientWithOptions(server.URL, "x", DefaultOptions().SetBatchSize(2).SetFlushInterval(10000))
+	defer c.Close()
+	writeApi := c.WriteApi("my-org", "my-bucket")
+	input := "test a=1i 1\ntest a=2i 2\ntest a=3i 3\n"
+	var batches []int
+	onBatch := func(n int) {
+		// batch is reported once it is written
+		batches = append(batches, n)
+		assert.Equal(t, sum(batches), written())
+	}
+	err := writeApi.WriteScanner(context.Background(), bufio.NewScanner(strings.NewReader(input)), onBatch)
+	require.NotNil(t, err)
+	perror, ok := err.(*Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusTooManyRequests, perror.StatusCode)
+	assert.Len(t, batches, 0)
+
+	// failed batch is not kept for retrying, so the next write is not held by the retry queue
+	err = writeApi.WriteScanner(context.Background(), bufio.NewScanner(strings.NewReader(input)), onBatch)
+	require.Nil(t, err)
+	assert.Equal(t, []int{2, 1}, batches)
+	assert.Equal(t, 3, written())
+	assert.Equal(t, uint(0), writeApi.Stats().BufferedBytes)
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func TestWriteMaxLineBytesAsync(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(5).SetMaxLineBytes(100)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	errCh := writeApi.Errors()
+	var recErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		recErr = <-errCh
+		wg.Done()
+	}()
+	writeApi.WriteRecord("test,id=0 f=1i")
+	writeApi.WritePoint(NewPoint("test", map[string]string{"id": "1"}, map[string]interface{}{"s": strings.Repeat("x", 100)}, time.Unix(0, 10)))
+	writeApi.WriteRecord("test,id=2 f=2i")
+	wg.Wait()
+	writeApi.Close()
+	require.NotNil(t, recErr)
+	assert.Equal(t, "line protocol record of 117 bytes exceeds maximum length 100 bytes", recErr.Error())
+	assert.Equal(t, []string{"test,id=0 f=1i", "test,id=2 f=2i"}, client.Lines())
+}
+
+func TestWriteMaxFieldsPerPointAsync(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(5).SetMaxFieldsPerPoint(1)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	errCh := writeApi.Errors()
+	var recErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		recErr = <-errCh
+		wg.Done()
+	}()
+	writeApi.WritePoint(NewPoint("test", map[string]string{"id": "0"}, map[string]interface{}{"f": 1}, time.Unix(0, 10)))
+	writeApi.WritePoint(NewPoint("test", map[string]string{"id": "1"}, map[string]interface{}{"f": 1, "g": 2}, time.Unix(0, 10)))
+	wg.Wait()
+	writeApi.Close()
+	require.NotNil(t, recErr)
+	assert.True(t, errors.Is(recErr, ErrTooManyFields))
+	assert.Equal(t, []string{"test,id=0 f=1i 10"}, client.Lines())
+}
+
+func TestWriteIdempotencyKey(t *testing.T) {
+	var keys []string
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	client := NewClientWithOptions(server.URL, "a", DefaultOptions().SetUseIdempotencyKey(true))
+	service := newWriteService("my-org", "my-bucket", client)
+
+	b := &batch{batch: "test f=1i 1\n"}
+	require.NotNil(t, service.writeBatch(context.Background(), b))
+	require.False(t, service.retryQueue.isEmpty())
+	require.Nil(t, service.writeBatch(context.Background(), service.retryQueue.pop()))
+	require.Nil(t, service.writeBatch(context.Background(), &batch{batch: "test f=2i 1\n"}))
+	require.Len(t, keys, 3)
+	assert.Len(t, keys[0], 64)
+	assert.Equal(t, keys[0], keys[1])
+	assert.NotEqual(t, keys[0], keys[2])
+
+	keys = keys[:0]
+	client.Options().SetUseIdempotencyKey(false)
+	require.Nil(t, service.writeBatch(context.Background(), b))
+	assert.Equal(t, []string{""}, keys)
+}
+
+func TestWriteBufferedCount(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(5).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	points := genPoints(3)
+	for _, p := range points {
+		writeApi.WritePoint(p)
+	}
+	writeApi.WriteRecord("test,a=1 f=1i 1")
+	// records are added to the buffer by the background goroutine
+	for i := 0; i < 100 && writeApi.BufferedCount() < 4; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 4, writeApi.BufferedCount())
+	assert.Len(t, client.Lines(), 0)
+	writeApi.Flush()
+	assert.Equal(t, 0, writeApi.BufferedCount())
+	assert.Len(t, client.Lines(), 4)
+	writeApi.Close()
+}
+
+func TestBufferSeparators(t *testing.T) {
+	lines := []string{
+		"test,a=1 f=1i 1\n",
+		"test,a=2 f=2i 2",
+		"test,a=3 f=3i 3\n\n",
+		"",
+		"test,a=4 f=4i 4",
+	}
+	assert.Equal(t, "test,a=1 f=1i 1\ntest,a=2 f=2i 2\ntest,a=3 f=3i 3\ntest,a=4 f=4i 4\n", buffer(lines))
+	assert.Equal(t, "", buffer(nil))
+}
+
+func TestFlushMeasurement(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(10).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	writeApi.WriteRecord("cpu,host=a usage=1 1")
+	writeApi.WriteRecord("mem,host=a free=1i 1")
+	writeApi.WriteRecord("cpu,host=b usage=2 1")
+	writeApi.WriteRecord(`disk\ io,host=a read=1i 1`)
+	writeApi.FlushMeasurement("cpu")
+	assert.Equal(t, []string{"cpu,host=a usage=1 1", "cpu,host=b usage=2 1"}, client.Lines())
+	assert.Equal(t, 2, writeApi.BufferedCount())
+
+	client.Close()
+	writeApi.FlushMeasurement("disk io")
+	assert.Equal(t, []string{`disk\ io,host=a read=1i 1`}, client.Lines())
+	writeApi.FlushMeasurement("none")
+	assert.Len(t, client.Lines(), 1)
+
+	client.Close()
+	writeApi.Close()
+	assert.Equal(t, []string{"mem,host=a free=1i 1"}, client.Lines())
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	assert.Equal(t, []int{429, 503}, client.options.RetryableStatusCodes())
+	client.SetReplyError(&Error{
+		StatusCode: 500,
+		Code:       "internal error",
+		Message:    "proxy error",
+	})
+	service := newWriteService("my-org", "my-bucket", client)
+	require.NotNil(t, service.writeBatch(context.Background(), &batch{batch: "test f=1i 1\n"}))
+	assert.True(t, service.retryQueue.isEmpty())
+
+	client.options.SetRetryableStatusCodes(500, 503)
+	require.NotNil(t, service.writeBatch(context.Background(), &batch{batch: "test f=1i 1\n"}))
+	require.False(t, service.retryQueue.isEmpty())
+	assert.Equal(t, "test f=1i 1\n", service.retryQueue.first().batch)
+	// first retry interval is RetryInterval shortened by jitter
+	assert.True(t, service.retryQueue.first().retryInterval <= client.options.RetryInterval())
+	assert.True(t, service.retryQueue.first().retryInterval >= client.options.RetryInterval()/2)
+}
+
+func TestRetryIntervalBackoff(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetRetryInterval(1000).SetMaxRetryInterval(10000)
+	assert.Equal(t, uint(125000), DefaultOptions().MaxRetryInterval())
+	service := newWriteService("my-org", "my-bucket", client)
+	// interval of retry n is within [RetryInterval*2^n/2, RetryInterval*2^n], limited by MaxRetryInterval
+	bounds := []uint{1000, 2000, 4000, 8000, 10000, 10000}
+	for i := 0; i < 100; i++ {
+		prev := uint(0)
+		for retries, max := range bounds {
+			interval := service.retryInterval(uint(retries))
+			assert.True(t, interval <= max, "retry %d interval %d exceeds %d", retries, interval, max)
+			assert.True(t, interval >= max/2, "retry %d interval %d below %d", retries, interval, max/2)
+			assert.True(t, interval >= prev || max == 10000, "retry %d interval %d doesn't grow from %d", retries, interval, prev)
+			prev = interval
+		}
+	}
+
+	// Retry-After sent by server is honored
+	client.SetReplyError(&Error{StatusCode: 429, Code: "too many requests", RetryAfter: 30})
+	require.NotNil(t, service.writeBatch(context.Background(), &batch{batch: "test f=1i 1\n", retries: 1}))
+	require.False(t, service.retryQueue.isEmpty())
+	assert.Equal(t, uint(30000), service.retryQueue.first().retryInterval)
+
+	// no limit
+	client.options.SetMaxRetryInterval(0)
+	interval := service.retryInterval(10)
+	assert.True(t, interval >= 512000 && interval <= 1024000)
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	points := genPoints(3)
+	require.Nil(t, writeApi.WritePoint(points[0]))
+	require.Nil(t, writeApi.WriteRecord("test,a=1 f=1i 1"))
+	writeApi.Close()
+	require.Len(t, client.Lines(), 2)
+
+	assert.Equal(t, ErrWriteApiClosed, writeApi.WritePoint(points[1]))
+	assert.Equal(t, ErrWriteApiClosed, writeApi.WriteRecord("test,a=2 f=2i 2"))
+	assert.Equal(t, ErrWriteApiClosed, writeApi.WritePointNow(context.Background(), points[2]))
+	// repeated close is no-op
+	writeApi.Close()
+	assert.Len(t, client.Lines(), 2)
+}
+
+func TestWriteConcurrentWithClose(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(5)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	points := genPoints(100)
+	var wg sync.WaitGroup
+	var written int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(points); j += 4 {
+				if writeApi.WritePoint(points[j]) == nil {
+					atomic.AddInt32(&written, 1)
+				}
+			}
+		}(i)
+	}
+	time.Sleep(time.Millisecond)
+	writeApi.Close()
+	wg.Wait()
+	assert.Len(t, client.Lines(), int(atomic.LoadInt32(&written)))
+}
+
+func TestWritePointAsync(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(2).SetFlushInterval(10000)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	points := genPoints(5)
+	res1 := writeApi.WritePointAsync(points[0])
+	// result is available when the batch is written
+	select {
+	case <-res1:
+		assert.Fail(t, "result before batch is written")
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.Nil(t, writeApi.WritePoint(points[1]))
+	assert.Nil(t, <-res1)
+	require.Len(t, client.Lines(), 2)
+
+	client.SetReplyError(&Error{StatusCode: 400, Code: "invalid", Message: "data"})
+	res2 := writeApi.WritePointAsync(points[2])
+	res3 := writeApi.WritePointAsync(points[3])
+	err := <-res2
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid: data", err.Error())
+	assert.Equal(t, err, <-res3)
+
+	// retried batch is resolved once written
+	client.SetReplyError(&Error{StatusCode: 503})
+	client.options.SetRetryInterval(1)
+	res4 := writeApi.WritePointAsync(points[4])
+	writeApi.Flush()
+	select {
+	case <-res4:
+		assert.Fail(t, "result before batch is retried")
+	default:
+	}
+	client.SetReplyError(nil)
+	time.Sleep(5 * time.Millisecond)
+	writeApi.Flush()
+	require.Nil(t, writeApi.WriteRecord("test,a=1 f=1i 1"))
+	writeApi.Flush()
+	assert.Nil(t, <-res4)
+
+	writeApi.Close()
+	assert.Equal(t, ErrWriteApiClosed, <-writeApi.WritePointAsync(points[0]))
+}
+
+func TestWriteCallbacks(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(1).SetRetryInterval(1)
+	writeApi := newWriteApiImpl("my-org", "my-bucket", client)
+	var lock sync.Mutex
+	var written []string
+	var failed []string
+	retry := false
+	writeApi.SetWriteSuccessCallback(func(batch string) {
+		lock.Lock()
+		defer lock.Unlock()
+		written = append(written, batch)
+	})
+	writeApi.SetWriteFailedCallback(func(batch string, err error, retryAttempt uint) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, "unavailable: down", err.Error())
+		failed = append(failed, fmt.Sprintf("%d:%s", retryAttempt, batch))
+		return retry
+	})
+
+	require.Nil(t, writeApi.WriteRecord("test,a=1 f=1i 1"))
+	writeApi.waitForFlushing()
+
+	// batch is discarded by the callback
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "down"})
+	require.Nil(t, writeApi.WriteRecord("test,a=2 f=2i 2"))
+	writeApi.waitForFlushing()
+	client.SetReplyError(nil)
+	require.Nil(t, writeApi.WriteRecord("test,a=3 f=3i 3"))
+	writeApi.waitForFlushing()
+	assert.Equal(t, []string{"test,a=1 f=1i 1", "test,a=3 f=3i 3"}, client.Lines())
+
+	// batch is kept for retrying by the callback
+	lock.Lock()
+	retry = true
+	lock.Unlock()
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "down"})
+	require.Nil(t, writeApi.WriteRecord("test,a=4 f=4i 4"))
+	writeApi.waitForFlushing()
+	client.SetReplyError(nil)
+	time.Sleep(5 * time.Millisecond)
+	require.Nil(t, writeApi.WriteRecord("test,a=5 f=5i 5"))
+	writeApi.waitForFlushing()
+	writeApi.Close()
+
+	assert.Equal(t, []string{"test,a=1 f=1i 1", "test,a=3 f=3i 3", "test,a=4 f=4i 4", "test,a=5 f=5i 5"}, client.Lines())
+	assert.Equal(t, []string{"0:test,a=2 f=2i 2\n", "0:test,a=4 f=4i 4\n"}, failed)
+	assert.Equal(t, []string{"test,a=1 f=1i 1\n", "test,a=3 f=3i 3\n", "test,a=4 f=4i 4\n", "test,a=5 f=5i 5\n"}, written)
+}
+
+func TestWriteApiWithContext(t *testing.T) {
+	client := &testClient{
+		options: DefaultOptions(),
+		t:       t,
+	}
+	client.options.SetBatchSize(1).SetRetryInterval(10000)
+	ctx, cancel := context.WithCancel(context.Background())
+	writeApi := newWriteApiImplWithContext(ctx, "my-org", "my-bucket", client)
+	errCh := writeApi.Errors()
+	points := genPoints(3)
+
+	// the first point must be written before the server starts failing
+	require.Nil(t, <-writeApi.WritePointAsync(points[0]))
+	client.SetReplyError(&Error{StatusCode: 503, Code: "unavailable", Message: "down"})
+	res1 := writeApi.WritePointAsync(points[1])
+	assert.Equal(t, client.ReplyError(), <-errCh)
+	require.Len(t, client.Lines(), 1)
+
+	// pending batch in the retry queue and new batches are not written after cancel
+	cancel()
+	client.SetReplyError(nil)
+	res2 := writeApi.WritePointAsync(points[2])
+	assert.Equal(t, context.Canceled, <-errCh)
+	assert.Equal(t, context.Canceled, <-res1)
+	assert.Equal(t, context.Canceled, <-res2)
+	assert.True(t, writeApi.service.retryQueue.isEmpty())
+	require.Len(t, client.Lines(), 1)
+	go func() {
+		for range errCh {
+		}
+	}()
+	writeApi.Close()
+	assert.Equal(t, uint(0), writeApi.Stats().BufferedBytes)
+}